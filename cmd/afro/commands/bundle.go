@@ -0,0 +1,161 @@
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"afro/pkg/chain"
+
+	"github.com/spf13/viper"
+)
+
+// loadBundle reads the bundle from the active viper config.
+func loadBundle() (*chain.Bundle, error) {
+	bundle := &chain.Bundle{
+		BaseURL:   viper.GetString("base_url"),
+		Headers:   viper.GetStringMapString("headers"),
+		Requests:  make(map[string]chain.Request),
+		Chains:    make(map[string][]chain.ChainStep),
+		Variables: viper.GetStringMapString("variables"),
+		Timeout:   viper.GetDuration("timeout"),
+		OpenAPI:   viper.GetString("openapi"),
+	}
+	if config := viper.ConfigFileUsed(); config != "" {
+		bundle.Dir = filepath.Dir(config)
+	}
+
+	retry, err := loadRetryPolicy("retry")
+	if err != nil {
+		return nil, err
+	}
+	bundle.Retry = retry
+
+	if err := viper.UnmarshalKey("environments", &bundle.Environments); err != nil {
+		return nil, fmt.Errorf("failed to parse environments: %w", err)
+	}
+
+	for name := range viper.GetStringMap("requests") {
+		req, err := loadRequest(name)
+		if err != nil {
+			return nil, err
+		}
+		bundle.Requests[name] = req
+	}
+
+	for name := range viper.GetStringMap("chains") {
+		steps, opts, err := loadChain(name)
+		if err != nil {
+			return nil, err
+		}
+		bundle.Chains[name] = steps
+		if opts != nil {
+			if bundle.ChainOptions == nil {
+				bundle.ChainOptions = make(map[string]chain.ChainOptions)
+			}
+			bundle.ChainOptions[name] = *opts
+		}
+	}
+
+	return bundle, nil
+}
+
+// loadRequest reads a saved request, accepting headers either as a map or as a list.
+func loadRequest(name string) (chain.Request, error) {
+	key := fmt.Sprintf("requests.%s", name)
+
+	var headers []string
+	if headerMap := viper.GetStringMapString(key + ".headers"); len(headerMap) > 0 {
+		for k, v := range headerMap {
+			headers = append(headers, fmt.Sprintf("%s: %s", k, v))
+		}
+	} else {
+		headers = viper.GetStringSlice(key + ".headers")
+	}
+
+	retry, err := loadRetryPolicy(key + ".retry")
+	if err != nil {
+		return chain.Request{}, err
+	}
+
+	return chain.Request{
+		Method:    viper.GetString(key + ".method"),
+		URL:       viper.GetString(key + ".url"),
+		Body:      viper.GetString(key + ".body"),
+		Headers:   headers,
+		NoHeaders: viper.GetBool(key + ".no_headers"),
+		Timeout:   viper.GetDuration(key + ".timeout"),
+		Retry:     retry,
+	}, nil
+}
+
+// loadChain reads a chain written either as a list of steps, or as a mapping
+// of its options with the steps under `steps:`.
+func loadChain(name string) ([]chain.ChainStep, *chain.ChainOptions, error) {
+	key := "chains." + name
+	if _, ok := viper.Get(key).(map[string]interface{}); !ok {
+		var steps []chain.ChainStep
+		if err := viper.UnmarshalKey(key, &steps); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse chain '%s': %w", name, err)
+		}
+		return steps, nil, nil
+	}
+
+	var def struct {
+		chain.ChainOptions `mapstructure:",squash"`
+		Steps              []chain.ChainStep `mapstructure:"steps"`
+	}
+	if err := viper.UnmarshalKey(key, &def); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse chain '%s': %w", name, err)
+	}
+	return def.Steps, &def.ChainOptions, nil
+}
+
+// loadRetryPolicy reads a retry block, or returns nil if key is not set.
+func loadRetryPolicy(key string) (*chain.RetryPolicy, error) {
+	if !viper.IsSet(key) {
+		return nil, nil
+	}
+	var policy chain.RetryPolicy
+	if err := viper.UnmarshalKey(key, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse '%s': %w", key, err)
+	}
+	return &policy, nil
+}
+
+// bundleFilename returns the file a new bundle is written to.
+func bundleFilename() string {
+	bundle, _ := rootCmd.Flags().GetString("bundle")
+	if bundle != "" {
+		return bundle + ".yaml"
+	}
+	return "afro.yaml"
+}
+
+// writeBundle writes the active config back to its file, creating the bundle
+// file if no config was loaded. It returns the name of the file written.
+func writeBundle() (string, error) {
+	if err := viper.WriteConfig(); err != nil {
+		if viper.ConfigFileUsed() != "" {
+			return "", err
+		}
+		filename := bundleFilename()
+		if err := viper.WriteConfigAs(filename); err != nil {
+			return "", err
+		}
+		return filename, nil
+	}
+	return viper.ConfigFileUsed(), nil
+}
+
+// newRunner builds a chain runner for the active bundle.
+func newRunner() (*chain.Runner, error) {
+	bundle, err := loadBundle()
+	if err != nil {
+		return nil, err
+	}
+	runner := chain.New(bundle)
+	runner.Environment = envName
+	runner.Timeout = timeout
+	runner.Retries = retries
+	return runner, nil
+}
@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestLoadBundleChainForms(t *testing.T) {
+	viper.Reset()
+	viper.SetConfigType("yaml")
+	err := viper.ReadConfig(strings.NewReader(`
+timeout: 5s
+requests:
+  login:
+    method: POST
+    url: /login
+    retry:
+      max_attempts: 3
+chains:
+  list_flow:
+    - request: login
+      on_status:
+        401:
+          - request: login
+        5xx:
+          - request: login
+  lenient_flow:
+    strict: false
+    outputs: [token]
+    steps:
+      - request: login
+        timeout: 2s
+        continue_on_error: true
+        snapshot:
+          ignore: [$.id]
+        assert:
+          - left: status
+            op: "=="
+            right: "200"
+            soft: true
+`))
+	if err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+
+	bundle, err := loadBundle()
+	if err != nil {
+		t.Fatalf("loadBundle failed: %v", err)
+	}
+
+	if bundle.Timeout != 5*time.Second {
+		t.Errorf("expected bundle timeout 5s, got %s", bundle.Timeout)
+	}
+	if retry := bundle.Requests["login"].Retry; retry == nil || retry.MaxAttempts != 3 {
+		t.Errorf("expected request retry policy, got %+v", retry)
+	}
+
+	steps := bundle.Chains["list_flow"]
+	if len(steps) != 1 || len(steps[0].OnStatus["401"]) != 1 || len(steps[0].OnStatus["5xx"]) != 1 {
+		t.Errorf("unexpected list chain %+v", steps)
+	}
+	if _, ok := bundle.ChainOptions["list_flow"]; ok {
+		t.Error("expected no options for a list chain")
+	}
+
+	steps = bundle.Chains["lenient_flow"]
+	if len(steps) != 1 || steps[0].Request != "login" || steps[0].Timeout != 2*time.Second {
+		t.Errorf("unexpected mapping chain %+v", steps)
+	}
+	if !steps[0].ContinueOnError || len(steps[0].Assert) != 1 || !steps[0].Assert[0].Soft {
+		t.Errorf("expected continue_on_error and a soft assertion, got %+v", steps[0])
+	}
+	if snap := steps[0].Snapshot; snap == nil || len(snap.Ignore) != 1 || snap.Ignore[0] != "$.id" {
+		t.Errorf("expected a snapshot ignoring $.id, got %+v", snap)
+	}
+	if strict := bundle.ChainOptions["lenient_flow"].Strict; strict == nil || *strict {
+		t.Errorf("expected strict: false, got %v", strict)
+	}
+	if outputs := bundle.ChainOptions["lenient_flow"].Outputs; len(outputs) != 1 || outputs[0] != "token" {
+		t.Errorf("expected outputs [token], got %v", outputs)
+	}
+}
@@ -44,7 +44,10 @@ func TestDynamicVariables(t *testing.T) {
 	os.Stderr = w
 	os.Stdout = w
 
-	err := runChain(context.Background(), "dynamic_flow")
+	runner, err := newRunner()
+	if err == nil {
+		err = runner.Run(context.Background(), "dynamic_flow")
+	}
 
 	w.Close()
 	os.Stderr = oldStderr
@@ -52,7 +55,7 @@ func TestDynamicVariables(t *testing.T) {
 	r.Close()
 
 	if err != nil {
-		t.Errorf("runChain failed: %v", err)
+		t.Errorf("Run failed: %v", err)
 	}
 
 	if !strings.Contains(capturedEmail, "user_") || !strings.Contains(capturedEmail, "@test.com") {
@@ -103,7 +106,10 @@ func TestAssertions(t *testing.T) {
 	os.Stderr = w
 	os.Stdout = w
 
-	err := runChain(context.Background(), "pass_flow")
+	runner, err := newRunner()
+	if err == nil {
+		err = runner.Run(context.Background(), "pass_flow")
+	}
 
 	w.Close()
 	os.Stderr = oldStderr
@@ -111,7 +117,7 @@ func TestAssertions(t *testing.T) {
 	r.Close()
 
 	if err != nil {
-		t.Errorf("runChain (pass) failed: %v", err)
+		t.Errorf("Run (pass) failed: %v", err)
 	}
 
 	// Test Failing Assertion
@@ -133,7 +139,10 @@ func TestAssertions(t *testing.T) {
 	os.Stderr = w2
 	os.Stdout = w2
 
-	errFail := runChain(context.Background(), "fail_flow")
+	runner, errFail := newRunner()
+	if errFail == nil {
+		errFail = runner.Run(context.Background(), "fail_flow")
+	}
 
 	w2.Close()
 	os.Stderr = oldStderr
@@ -141,7 +150,7 @@ func TestAssertions(t *testing.T) {
 	r2.Close()
 
 	if errFail == nil {
-		t.Errorf("expected runChain (fail) to fail, but it passed")
+		t.Errorf("expected Run (fail) to fail, but it passed")
 	} else if !strings.Contains(errFail.Error(), "assertion 1 failed") {
 		t.Errorf("expected assertion error, got: %v", errFail)
 	}
@@ -1,14 +1,18 @@
 package commands
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"sync/atomic"
 	"testing"
 
+	"afro/pkg/chain"
+
 	"github.com/spf13/viper"
 )
 
@@ -80,8 +84,12 @@ func TestChainBranching(t *testing.T) {
 	os.Stdout = w
 
 	// Run
-	if err := runChain(context.Background(), "test_flow"); err != nil {
-		t.Errorf("runChain failed: %v", err)
+	runner, err := newRunner()
+	if err != nil {
+		t.Fatalf("newRunner failed: %v", err)
+	}
+	if err := runner.Run(context.Background(), "test_flow"); err != nil {
+		t.Errorf("Run failed: %v", err)
 	}
 
 	// Restore stdout/stderr
@@ -98,3 +106,51 @@ func TestChainBranching(t *testing.T) {
 		t.Errorf("Expected data to be called twice, got %d", dataCalled)
 	}
 }
+
+func TestLoadBundleMixedCaseNames(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":1}`)
+	}))
+	defer ts.Close()
+
+	viper.Reset()
+	viper.SetConfigType("yaml")
+	err := viper.ReadConfig(strings.NewReader(`
+base_url: ` + ts.URL + `
+requests:
+  getUser:
+    method: GET
+    url: /user
+chains:
+  loginFlow:
+    outputs: [id]
+    steps:
+      - request: getUser
+        extract:
+          id: $.id
+  myFlow:
+    - chain: loginFlow
+    - request: getUser
+`))
+	if err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+
+	bundle, err := loadBundle()
+	if err != nil {
+		t.Fatalf("loadBundle failed: %v", err)
+	}
+	if _, ok := bundle.LookupChain("myFlow"); !ok {
+		t.Error("expected chain 'myFlow' to be found")
+	}
+
+	runner := chain.New(bundle)
+	runner.Out = &bytes.Buffer{}
+	runner.Log = nil
+	if err := runner.Run(context.Background(), "myFlow"); err != nil {
+		t.Errorf("Run failed: %v", err)
+	}
+	if status, err := runner.RunRequest(context.Background(), "getUser"); err != nil || status != 200 {
+		t.Errorf("RunRequest failed: %d %v", status, err)
+	}
+}
@@ -0,0 +1,125 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestEnvironments(t *testing.T) {
+	var gotPath, gotAuth, gotTenant string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotAuth = r.Header.Get("Authorization")
+		gotTenant = r.Header.Get("X-Tenant")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	viper.Reset()
+	viper.Set("base_url", "http://prod.invalid")
+	viper.Set("headers", map[string]string{"X-Tenant": "prod"})
+	viper.Set("environments.staging.base_url", ts.URL)
+	viper.Set("environments.staging.headers", map[string]string{"X-Tenant": "staging"})
+	viper.Set("environments.staging.variables", map[string]string{"token": "staging-token", "user_id": "42"})
+
+	viper.Set("requests.get_user.method", "GET")
+	viper.Set("requests.get_user.url", "/users/{{user_id}}")
+	viper.Set("requests.get_user.headers", []string{"Authorization: Bearer {{token}}"})
+
+	viper.Set("chains.env_flow", []map[string]interface{}{
+		{"request": "get_user"},
+	})
+
+	envName = "staging"
+	defer func() { envName = "" }()
+
+	oldStderr := os.Stderr
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+	os.Stdout = w
+
+	runner, err := newRunner()
+	if err == nil {
+		err = runner.Run(context.Background(), "env_flow")
+	}
+
+	w.Close()
+	os.Stderr = oldStderr
+	os.Stdout = oldStdout
+	r.Close()
+
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if gotPath != "/users/42" {
+		t.Errorf("Expected path /users/42, got %s", gotPath)
+	}
+	if gotAuth != "Bearer staging-token" {
+		t.Errorf("Expected environment token, got %q", gotAuth)
+	}
+	if gotTenant != "staging" {
+		t.Errorf("Expected environment header to override bundle header, got %q", gotTenant)
+	}
+
+	envName = "missing"
+	runner, err = newRunner()
+	if err == nil {
+		err = runner.Run(context.Background(), "env_flow")
+	}
+	if err == nil {
+		t.Errorf("Expected error for unknown environment")
+	}
+}
+
+func TestEnvironmentCamelCaseVariables(t *testing.T) {
+	var gotPath string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+	}))
+	defer ts.Close()
+
+	viper.Reset()
+	viper.SetConfigType("yaml")
+	err := viper.ReadConfig(strings.NewReader(`
+base_url: ` + ts.URL + `
+variables:
+  teamId: 7
+environments:
+  staging:
+    variables:
+      userId: 42
+requests:
+  getUser:
+    method: GET
+    url: /teams/{{teamId}}/users/{{userId}}
+`))
+	if err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+
+	envName = "staging"
+	defer func() { envName = "" }()
+	runner, err := newRunner()
+	if err != nil {
+		t.Fatalf("newRunner failed: %v", err)
+	}
+	runner.Out = &bytes.Buffer{}
+	runner.Log = nil
+	runner.Strict = true
+
+	if _, err := runner.RunRequest(context.Background(), "getUser"); err != nil {
+		t.Fatalf("RunRequest failed: %v", err)
+	}
+	if gotPath != "/teams/7/users/42" {
+		t.Errorf("expected camelCase variables to resolve, got %s", gotPath)
+	}
+}
@@ -0,0 +1,14 @@
+package commands
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export requests and chains for use with other tools",
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+}
@@ -0,0 +1,38 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var exportCurlCmd = &cobra.Command{
+	Use:   "curl [request-or-chain]",
+	Short: "Export a saved request as a curl command or a chain as a bash script",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		runner, err := newRunner()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		var script string
+		if _, ok := runner.Bundle.LookupChain(name); ok {
+			script, err = runner.CurlScript(name)
+		} else {
+			script, err = runner.CurlCommand(name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(script)
+	},
+}
+
+func init() {
+	exportCmd.AddCommand(exportCurlCmd)
+}
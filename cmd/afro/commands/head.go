@@ -13,6 +13,8 @@ var headCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		opts := buildRequestOptions("HEAD", args, cmd)
+		// A HEAD response has no body, so the headers are the useful part.
+		opts.Output.Include = true
 		if _, err := makeRequest(cmd.Context(), opts, os.Stdout); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
@@ -0,0 +1,148 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import requests into the bundle from other tools",
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+}
+
+// importedBundle holds what an importer extracted from another tool's format.
+type importedBundle struct {
+	BaseURL      string
+	Headers      map[string]string
+	Requests     map[string]RequestOptions
+	Variables    map[string]string
+	Environments map[string]map[string]string
+	// OpenAPI is the path of the imported OpenAPI document, relative to the
+	// bundle file.
+	OpenAPI string
+}
+
+// saveImportedBundle merges the imported bundle into the active config and
+// writes it. Settings already present in the bundle are kept.
+func saveImportedBundle(imported importedBundle) {
+	if imported.BaseURL != "" {
+		if current := viper.GetString("base_url"); current == "" {
+			viper.Set("base_url", imported.BaseURL)
+		} else if current != imported.BaseURL {
+			fmt.Fprintf(os.Stderr, "Keeping existing base_url %s (imported %s)\n", current, imported.BaseURL)
+		}
+	}
+
+	if imported.OpenAPI != "" && viper.GetString("openapi") == "" {
+		viper.Set("openapi", imported.OpenAPI)
+	}
+
+	if len(imported.Headers) > 0 {
+		headers := viper.GetStringMapString("headers")
+		for k, v := range imported.Headers {
+			// viper keys are case insensitive
+			if _, ok := headers[strings.ToLower(k)]; !ok {
+				headers[k] = lowerPlaceholdersIn(v)
+			}
+		}
+		viper.Set("headers", headers)
+	}
+
+	if len(imported.Variables) > 0 {
+		viper.Set("variables", mergeVariables(viper.GetStringMapString("variables"), imported.Variables))
+	}
+
+	for env, vars := range imported.Environments {
+		key := fmt.Sprintf("environments.%s.variables", env)
+		viper.Set(key, mergeVariables(viper.GetStringMapString(key), vars))
+		fmt.Printf("Imported environment '%s'\n", env)
+	}
+
+	names := make([]string, 0, len(imported.Requests))
+	for name, opts := range imported.Requests {
+		setRequest(lowerPlaceholders(opts), name)
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	filename, err := writeBundle()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to save bundle: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, name := range names {
+		fmt.Printf("Imported request '%s'\n", name)
+	}
+	fmt.Printf("Saved %d requests to %s\n", len(names), filename)
+}
+
+// bundleRelativePath returns path relative to the directory of the bundle
+// file, so it resolves the same wherever afro is run from. It falls back to
+// the absolute path.
+func bundleRelativePath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	bundle := viper.ConfigFileUsed()
+	if bundle == "" {
+		bundle = bundleFilename()
+	}
+	dir, err := filepath.Abs(filepath.Dir(bundle))
+	if err != nil {
+		return abs
+	}
+	rel, err := filepath.Rel(dir, abs)
+	if err != nil {
+		return abs
+	}
+	return rel
+}
+
+// mergeVariables adds the imported variables to the existing ones, keeping
+// existing values.
+func mergeVariables(existing, imported map[string]string) map[string]string {
+	merged := make(map[string]string, len(existing)+len(imported))
+	for k, v := range imported {
+		merged[strings.ToLower(k)] = v
+	}
+	for k, v := range existing {
+		merged[k] = v
+	}
+	return merged
+}
+
+var importedPlaceholderPattern = regexp.MustCompile(`\{\{([^{}$]+)\}\}`)
+
+// lowerPlaceholders lowercases the variable names in the request's placeholders.
+// viper keys are case insensitive and stored lowercased, so variables defined
+// in the bundle could never match a camelCase placeholder.
+func lowerPlaceholders(opts RequestOptions) RequestOptions {
+	lower := lowerPlaceholdersIn
+	opts.URL = lower(opts.URL)
+	opts.Body = lower(opts.Body)
+	headers := make([]string, len(opts.Headers))
+	for i, h := range opts.Headers {
+		headers[i] = lower(h)
+	}
+	if len(headers) > 0 {
+		opts.Headers = headers
+	}
+	return opts
+}
+
+func lowerPlaceholdersIn(s string) string {
+	return importedPlaceholderPattern.ReplaceAllStringFunc(s, strings.ToLower)
+}
@@ -0,0 +1,378 @@
+package commands
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var importCurlCmd = &cobra.Command{
+	Use:   "curl [name] [curl-command]",
+	Short: "Import a curl command as a saved request",
+	Long: `Import a curl command as a saved request. The curl command is read from stdin
+when it is not passed as an argument, so snippets can be pasted as they are.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+
+		var command string
+		if len(args) == 2 {
+			command = args[1]
+		} else {
+			input, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: failed to read curl command: %v\n", err)
+				os.Exit(1)
+			}
+			command = string(input)
+		}
+
+		opts, err := parseCurl(command)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		opts.URL = relativeURL(opts.URL, bundleBaseURLs())
+
+		saveRequest(opts, name)
+	},
+}
+
+func init() {
+	importCmd.AddCommand(importCurlCmd)
+}
+
+// parseCurl converts a curl command line into request options.
+func parseCurl(command string) (RequestOptions, error) {
+	args, err := splitShellWords(command)
+	if err != nil {
+		return RequestOptions{}, err
+	}
+	if len(args) > 0 && (args[0] == "curl" || strings.HasSuffix(args[0], "/curl")) {
+		args = args[1:]
+	}
+
+	var (
+		opts    RequestOptions
+		rawURL  string
+		data    []string
+		getData bool
+	)
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		// Split "--flag=value" and attached short values such as "-XPOST".
+		flag, value, hasValue := arg, "", false
+		if strings.HasPrefix(arg, "--") {
+			if idx := strings.Index(arg, "="); idx > 0 {
+				flag, value, hasValue = arg[:idx], arg[idx+1:], true
+			}
+		} else if strings.HasPrefix(arg, "-") && len(arg) > 2 && curlShortFlagsWithValue[arg[:2]] {
+			flag, value, hasValue = arg[:2], arg[2:], true
+		}
+
+		next := func() (string, error) {
+			if hasValue {
+				return value, nil
+			}
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("curl option %s requires a value", flag)
+			}
+			i++
+			return args[i], nil
+		}
+
+		switch flag {
+		case "-X", "--request":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			opts.Method = strings.ToUpper(v)
+		case "-H", "--header":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			opts.Headers = append(opts.Headers, v)
+		case "-d", "--data", "--data-raw", "--data-binary", "--data-ascii":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			data = append(data, v)
+		case "--data-urlencode":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			if idx := strings.Index(v, "="); idx >= 0 {
+				v = v[:idx+1] + url.QueryEscape(v[idx+1:])
+			} else {
+				v = url.QueryEscape(v)
+			}
+			data = append(data, v)
+		case "--json":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			data = append(data, v)
+			opts.Headers = append(opts.Headers, "Content-Type: application/json", "Accept: application/json")
+		case "-u", "--user":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			opts.Headers = append(opts.Headers, "Authorization: Basic "+base64.StdEncoding.EncodeToString([]byte(v)))
+		case "-b", "--cookie":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			opts.Headers = append(opts.Headers, "Cookie: "+v)
+		case "-A", "--user-agent":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			opts.Headers = append(opts.Headers, "User-Agent: "+v)
+		case "-e", "--referer":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			opts.Headers = append(opts.Headers, "Referer: "+v)
+		case "--url":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			rawURL = v
+		case "-F", "--form", "--form-string":
+			return opts, fmt.Errorf("curl option %s is not supported: multipart form bodies cannot be saved as a request", flag)
+		case "-G", "--get":
+			getData = true
+		case "-I", "--head":
+			opts.Method = "HEAD"
+		default:
+			if curlIgnoredFlagsWithValue[flag] {
+				if _, err := next(); err != nil {
+					return opts, err
+				}
+				continue
+			}
+			if strings.HasPrefix(arg, "-") {
+				// Flags that only affect curl's own behaviour, e.g. -s, -L,
+				// --compressed, are skipped. Any other flag may take a value
+				// or change the request, so it is not guessed at.
+				if !curlIgnoredFlag(arg) {
+					return opts, fmt.Errorf("unsupported curl option %s", flag)
+				}
+				continue
+			}
+			if rawURL == "" {
+				rawURL = arg
+			}
+		}
+	}
+
+	if rawURL == "" {
+		return opts, fmt.Errorf("no URL found in curl command")
+	}
+
+	body := strings.Join(data, "&")
+	if getData && body != "" {
+		if strings.Contains(rawURL, "?") {
+			rawURL += "&" + body
+		} else {
+			rawURL += "?" + body
+		}
+		body = ""
+	}
+
+	if opts.Method == "" {
+		if body != "" {
+			opts.Method = "POST"
+		} else {
+			opts.Method = "GET"
+		}
+	}
+	if body != "" && !hasHeader(opts.Headers, "Content-Type") {
+		opts.Headers = append(opts.Headers, "Content-Type: application/x-www-form-urlencoded")
+	}
+
+	opts.URL = rawURL
+	opts.Body = body
+	return opts, nil
+}
+
+var curlShortFlagsWithValue = map[string]bool{
+	"-X": true, "-H": true, "-d": true, "-u": true, "-b": true, "-A": true, "-e": true,
+	"-o": true, "-m": true, "-w": true, "-x": true, "-c": true, "-F": true,
+}
+
+var curlIgnoredFlagsWithValue = map[string]bool{
+	"-o":                true,
+	"--output":          true,
+	"-m":                true,
+	"--max-time":        true,
+	"--connect-timeout": true,
+	"-w":                true,
+	"--write-out":       true,
+	"-x":                true,
+	"--proxy":           true,
+	"-c":                true,
+	"--cookie-jar":      true,
+	"--retry":           true,
+	"--cacert":          true,
+	"--cert":            true,
+	"--key":             true,
+}
+
+var curlIgnoredFlags = map[string]bool{
+	"--silent":         true,
+	"--show-error":     true,
+	"--location":       true,
+	"--insecure":       true,
+	"--verbose":        true,
+	"--include":        true,
+	"--compressed":     true,
+	"--fail":           true,
+	"--fail-with-body": true,
+	"--globoff":        true,
+	"--no-buffer":      true,
+	"--progress-bar":   true,
+	"--http1.1":        true,
+	"--http2":          true,
+	"--ipv4":           true,
+	"--ipv6":           true,
+}
+
+// curlIgnoredShortFlags lists the single-letter flags that only affect curl's
+// own behaviour. They may be combined, as in -sSL.
+const curlIgnoredShortFlags = "sSLkvifgN#46"
+
+// curlIgnoredFlag reports whether a flag only affects curl's own behaviour.
+func curlIgnoredFlag(arg string) bool {
+	if strings.HasPrefix(arg, "--") {
+		return curlIgnoredFlags[arg]
+	}
+	for _, c := range arg[1:] {
+		if !strings.ContainsRune(curlIgnoredShortFlags, c) {
+			return false
+		}
+	}
+	return len(arg) > 1
+}
+
+func hasHeader(headers []string, name string) bool {
+	for _, h := range headers {
+		parts := strings.SplitN(h, ":", 2)
+		if strings.EqualFold(strings.TrimSpace(parts[0]), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// bundleBaseURLs returns the base URLs requests in the bundle are relative to.
+func bundleBaseURLs() []string {
+	baseURLs := []string{viper.GetString("base_url")}
+	if envName != "" {
+		baseURLs = append(baseURLs, viper.GetString("environments."+envName+".base_url"))
+	}
+	return baseURLs
+}
+
+// relativeURL strips the first matching base URL from rawURL.
+func relativeURL(rawURL string, baseURLs []string) string {
+	for _, baseURL := range baseURLs {
+		baseURL = strings.TrimSuffix(baseURL, "/")
+		if baseURL == "" || !strings.HasPrefix(rawURL, baseURL) {
+			continue
+		}
+		rest := strings.TrimPrefix(rawURL, baseURL)
+		if rest == "" {
+			return "/"
+		}
+		if strings.HasPrefix(rest, "/") {
+			return rest
+		}
+		if strings.HasPrefix(rest, "?") {
+			return "/" + rest
+		}
+	}
+	return rawURL
+}
+
+// splitShellWords splits a command line into words the way a POSIX shell
+// would, handling quotes, escapes and line continuations.
+func splitShellWords(s string) ([]string, error) {
+	var (
+		words   []string
+		current strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+
+	for _, c := range s {
+		switch {
+		case escaped:
+			// A backslash-newline is a line continuation. Inside double quotes
+			// only a few characters can be escaped.
+			if quote == '"' && !strings.ContainsRune("$`\"\\\n", c) {
+				current.WriteRune('\\')
+			}
+			if c != '\n' {
+				current.WriteRune(c)
+				inWord = true
+			}
+			escaped = false
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(c)
+			}
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else if c == '\\' {
+				escaped = true
+			} else {
+				current.WriteRune(c)
+			}
+		case c == '\\':
+			escaped = true
+		case c == '\'' || c == '"':
+			quote = c
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(c)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in command")
+	}
+	if inWord {
+		words = append(words, current.String())
+	}
+	return words, nil
+}
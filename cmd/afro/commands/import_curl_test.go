@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCurl(t *testing.T) {
+	command := `curl -X PUT 'https://api.etin.dev/users/1?verbose=true' \
+  -H "Content-Type: application/json" \
+  -H 'X-Trace: abc' \
+  --data-raw '{"name": "Ada \"The\" Countess"}' \
+  -u ada:secret -sL`
+
+	opts, err := parseCurl(command)
+	if err != nil {
+		t.Fatalf("parseCurl failed: %v", err)
+	}
+
+	if opts.Method != "PUT" {
+		t.Errorf("Expected method PUT, got %s", opts.Method)
+	}
+	if opts.URL != "https://api.etin.dev/users/1?verbose=true" {
+		t.Errorf("Unexpected URL %s", opts.URL)
+	}
+	if opts.Body != `{"name": "Ada \"The\" Countess"}` {
+		t.Errorf("Unexpected body %s", opts.Body)
+	}
+	expectedHeaders := []string{
+		"Content-Type: application/json",
+		"X-Trace: abc",
+		"Authorization: Basic YWRhOnNlY3JldA==",
+	}
+	if !reflect.DeepEqual(opts.Headers, expectedHeaders) {
+		t.Errorf("Expected headers %v, got %v", expectedHeaders, opts.Headers)
+	}
+}
+
+func TestParseCurlDefaults(t *testing.T) {
+	tests := []struct {
+		command string
+		method  string
+		url     string
+		body    string
+	}{
+		{`curl https://api.etin.dev/items`, "GET", "https://api.etin.dev/items", ""},
+		{`curl --url https://api.etin.dev/items -d name=box`, "POST", "https://api.etin.dev/items", "name=box"},
+		{`curl -G https://api.etin.dev/items?page=2 -d q=box`, "GET", "https://api.etin.dev/items?page=2&q=box", ""},
+		{`curl -XDELETE "https://api.etin.dev/items/1"`, "DELETE", "https://api.etin.dev/items/1", ""},
+	}
+
+	for _, tt := range tests {
+		opts, err := parseCurl(tt.command)
+		if err != nil {
+			t.Errorf("parseCurl(%q) failed: %v", tt.command, err)
+			continue
+		}
+		if opts.Method != tt.method || opts.URL != tt.url || opts.Body != tt.body {
+			t.Errorf("parseCurl(%q) = %s %s %q, expected %s %s %q", tt.command, opts.Method, opts.URL, opts.Body, tt.method, tt.url, tt.body)
+		}
+	}
+
+	if _, err := parseCurl(`curl -H 'Accept: */*'`); err == nil {
+		t.Errorf("Expected error for curl command without URL")
+	}
+}
+
+func TestParseCurlFlags(t *testing.T) {
+	opts, err := parseCurl(`curl -sS --compressed -k --max-time 5 -o out.json --location https://api.etin.dev/items`)
+	if err != nil {
+		t.Fatalf("parseCurl failed: %v", err)
+	}
+	if opts.Method != "GET" || opts.URL != "https://api.etin.dev/items" {
+		t.Errorf("Unexpected request %s %s", opts.Method, opts.URL)
+	}
+
+	// Options that would change the request, or whose value could be taken
+	// for the URL, are rejected rather than dropped.
+	tests := map[string]string{
+		`curl -F 'file=@photo.png' https://api.etin.dev/upload`:          "curl option -F is not supported: multipart form bodies",
+		`curl --form=name=ada https://api.etin.dev/upload`:               "curl option --form is not supported",
+		`curl -Fname=ada https://api.etin.dev/upload`:                    "curl option -F is not supported",
+		`curl --resolve api.etin.dev:443:127.0.0.1 https://api.etin.dev`: "unsupported curl option --resolve",
+		`curl -sZ https://api.etin.dev`:                                  "unsupported curl option -sZ",
+	}
+	for command, expected := range tests {
+		_, err := parseCurl(command)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("parseCurl(%q) error = %v, expected %q", command, err, expected)
+		}
+	}
+}
+
+func TestRelativeURL(t *testing.T) {
+	baseURLs := []string{"https://api.etin.dev/", ""}
+
+	tests := map[string]string{
+		"https://api.etin.dev/users?page=1": "/users?page=1",
+		"https://api.etin.dev":              "/",
+		"https://api.etin.dev.evil.com/x":   "https://api.etin.dev.evil.com/x",
+		"https://other.dev/users":           "https://other.dev/users",
+	}
+	for in, expected := range tests {
+		if got := relativeURL(in, baseURLs); got != expected {
+			t.Errorf("relativeURL(%q) = %q, expected %q", in, got, expected)
+		}
+	}
+}
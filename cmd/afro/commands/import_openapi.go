@@ -0,0 +1,382 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v3"
+)
+
+var importOpenAPICmd = &cobra.Command{
+	Use:   "openapi [spec.yaml|spec.json]",
+	Short: "Import the operations of an OpenAPI 3 spec as saved requests",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to read spec: %v\n", err)
+			os.Exit(1)
+		}
+
+		imported, err := parseOpenAPI(data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		// Kept so chain steps can validate responses against its schemas.
+		imported.OpenAPI = bundleRelativePath(args[0])
+
+		saveImportedBundle(imported)
+	},
+}
+
+func init() {
+	importCmd.AddCommand(importOpenAPICmd)
+}
+
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// maxExampleDepth stops example generation for recursive schemas.
+const maxExampleDepth = 8
+
+var (
+	pathParamPattern    = regexp.MustCompile(`\{([^{}]+)\}`)
+	requestNameReplacer = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+)
+
+// parseOpenAPI converts an OpenAPI 3 document (YAML or JSON) into saved requests.
+func parseOpenAPI(data []byte) (importedBundle, error) {
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return importedBundle{}, fmt.Errorf("failed to parse spec: %w", err)
+	}
+	if version, _ := doc["openapi"].(string); !strings.HasPrefix(version, "3.") {
+		return importedBundle{}, fmt.Errorf("unsupported spec: only OpenAPI 3 documents can be imported")
+	}
+
+	spec := openAPISpec{doc: doc}
+	imported := importedBundle{
+		BaseURL:  spec.serverURL(),
+		Requests: make(map[string]RequestOptions),
+	}
+
+	paths := asMap(doc["paths"])
+	for _, path := range sortedKeys(paths) {
+		pathItem := spec.resolve(paths[path])
+		pathParams := asSlice(pathItem["parameters"])
+
+		for _, method := range openAPIMethods {
+			operation := asMap(pathItem[method])
+			if operation == nil {
+				continue
+			}
+
+			name, _ := operation["operationId"].(string)
+			if name == "" {
+				name = method + path
+			}
+			name = operationName(name)
+
+			opts, err := spec.request(method, path, pathParams, operation)
+			if err != nil {
+				return importedBundle{}, fmt.Errorf("operation '%s': %w", name, err)
+			}
+			imported.Requests[name] = opts
+		}
+	}
+
+	return imported, nil
+}
+
+var camelCaseBoundary = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+
+// operationName turns an operationId such as getUserById into a request name
+// such as get_user_by_id. Names are saved lowercase, so keeping the camelCase
+// would report a name that differs from the saved one.
+func operationName(id string) string {
+	name := camelCaseBoundary.ReplaceAllString(id, "${1}_${2}")
+	return strings.ToLower(strings.Trim(requestNameReplacer.ReplaceAllString(name, "_"), "_"))
+}
+
+type openAPISpec struct {
+	doc map[string]interface{}
+}
+
+// serverURL returns the first server URL with its variables set to their defaults.
+func (s openAPISpec) serverURL() string {
+	servers := asSlice(s.doc["servers"])
+	if len(servers) == 0 {
+		return ""
+	}
+	server := asMap(servers[0])
+	url, _ := server["url"].(string)
+	for name, v := range asMap(server["variables"]) {
+		if def, ok := asMap(v)["default"]; ok {
+			url = strings.ReplaceAll(url, "{"+name+"}", fmt.Sprintf("%v", def))
+		}
+	}
+	return strings.TrimSuffix(url, "/")
+}
+
+func (s openAPISpec) request(method, path string, pathParams []interface{}, operation map[string]interface{}) (RequestOptions, error) {
+	opts := RequestOptions{
+		Method: strings.ToUpper(method),
+		URL:    pathParamPattern.ReplaceAllString(path, "{{$1}}"),
+	}
+
+	// Operation parameters override path-level parameters with the same name and location.
+	params := make(map[string]map[string]interface{})
+	var order []string
+	for _, p := range append(append([]interface{}{}, pathParams...), asSlice(operation["parameters"])...) {
+		param := s.resolve(p)
+		key := fmt.Sprintf("%v:%v", param["in"], param["name"])
+		if _, ok := params[key]; !ok {
+			order = append(order, key)
+		}
+		params[key] = param
+	}
+
+	var query []string
+	for _, key := range order {
+		param := params[key]
+		name, _ := param["name"].(string)
+		required, _ := param["required"].(bool)
+		if !required || name == "" {
+			continue
+		}
+		switch param["in"] {
+		case "header":
+			opts.Headers = append(opts.Headers, fmt.Sprintf("%s: {{%s}}", name, name))
+		case "query":
+			query = append(query, fmt.Sprintf("%s={{%s}}", name, name))
+		}
+	}
+	if len(query) > 0 {
+		opts.URL += "?" + strings.Join(query, "&")
+	}
+
+	if body := s.resolve(operation["requestBody"]); body != nil {
+		contentType, media := s.mediaType(asMap(body["content"]))
+		if media != nil {
+			example, ok := s.example(media)
+			if ok {
+				if str, isString := example.(string); isString && !strings.Contains(contentType, "json") {
+					opts.Body = str
+				} else {
+					encoded, err := json.Marshal(normalizeYAML(example))
+					if err != nil {
+						return opts, fmt.Errorf("failed to encode example body: %w", err)
+					}
+					opts.Body = string(encoded)
+				}
+			}
+			opts.Headers = append(opts.Headers, "Content-Type: "+contentType)
+		}
+	}
+
+	return opts, nil
+}
+
+// mediaType picks the JSON media type of a content map if there is one.
+func (s openAPISpec) mediaType(content map[string]interface{}) (string, map[string]interface{}) {
+	types := sortedKeys(content)
+	for _, t := range types {
+		if t == "application/json" {
+			return t, asMap(content[t])
+		}
+	}
+	for _, t := range types {
+		if strings.HasSuffix(t, "+json") {
+			return t, asMap(content[t])
+		}
+	}
+	if len(types) > 0 {
+		return types[0], asMap(content[types[0]])
+	}
+	return "", nil
+}
+
+// example returns the example of a media type, generating one from its schema
+// when none is given.
+func (s openAPISpec) example(media map[string]interface{}) (interface{}, bool) {
+	if example, ok := media["example"]; ok {
+		return example, true
+	}
+	if examples := asMap(media["examples"]); len(examples) > 0 {
+		first := s.resolve(examples[sortedKeys(examples)[0]])
+		if value, ok := first["value"]; ok {
+			return value, true
+		}
+	}
+	if schema := s.resolve(media["schema"]); schema != nil {
+		return s.schemaExample(schema, 0), true
+	}
+	return nil, false
+}
+
+func (s openAPISpec) schemaExample(schema map[string]interface{}, depth int) interface{} {
+	if example, ok := schema["example"]; ok {
+		return example
+	}
+	if examples := asSlice(schema["examples"]); len(examples) > 0 {
+		return examples[0]
+	}
+	if def, ok := schema["default"]; ok {
+		return def
+	}
+	if enum := asSlice(schema["enum"]); len(enum) > 0 {
+		return enum[0]
+	}
+	if depth > maxExampleDepth {
+		return nil
+	}
+
+	for _, key := range []string{"oneOf", "anyOf"} {
+		if options := asSlice(schema[key]); len(options) > 0 {
+			return s.schemaExample(s.resolve(options[0]), depth+1)
+		}
+	}
+	if all := asSlice(schema["allOf"]); len(all) > 0 {
+		merged := make(map[string]interface{})
+		for _, part := range all {
+			if obj, ok := s.schemaExample(s.resolve(part), depth+1).(map[string]interface{}); ok {
+				for k, v := range obj {
+					merged[k] = v
+				}
+			}
+		}
+		return merged
+	}
+
+	schemaType, _ := schema["type"].(string)
+	if types := asSlice(schema["type"]); len(types) > 0 {
+		// OpenAPI 3.1 allows a list of types, e.g. [string, "null"].
+		schemaType, _ = types[0].(string)
+	}
+	if schemaType == "" && schema["properties"] != nil {
+		schemaType = "object"
+	}
+
+	switch schemaType {
+	case "object":
+		obj := make(map[string]interface{})
+		properties := asMap(schema["properties"])
+		for _, name := range sortedKeys(properties) {
+			obj[name] = s.schemaExample(s.resolve(properties[name]), depth+1)
+		}
+		return obj
+	case "array":
+		if items := s.resolve(schema["items"]); items != nil {
+			return []interface{}{s.schemaExample(items, depth+1)}
+		}
+		return []interface{}{}
+	case "integer", "number":
+		return 0
+	case "boolean":
+		return false
+	case "string":
+		if format, ok := schema["format"].(string); ok {
+			return format
+		}
+		return "string"
+	default:
+		return nil
+	}
+}
+
+// resolve follows local $ref pointers such as "#/components/schemas/User".
+func (s openAPISpec) resolve(node interface{}) map[string]interface{} {
+	m := asMap(node)
+	for i := 0; i < maxExampleDepth && m != nil; i++ {
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return m
+		}
+		m = asMap(lookupPointer(s.doc, ref))
+	}
+	return m
+}
+
+// lookupPointer resolves a local JSON pointer reference within doc.
+func lookupPointer(doc interface{}, ref string) interface{} {
+	if !strings.HasPrefix(ref, "#") {
+		return nil
+	}
+	current := doc
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#"), "/") {
+		if part == "" {
+			continue
+		}
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		switch node := current.(type) {
+		case map[string]interface{}:
+			current = node[part]
+		case []interface{}:
+			var idx int
+			if _, err := fmt.Sscanf(part, "%d", &idx); err != nil || idx < 0 || idx >= len(node) {
+				return nil
+			}
+			current = node[idx]
+		default:
+			return nil
+		}
+	}
+	return current
+}
+
+// normalizeYAML converts maps with non-string keys, which YAML allows, so the
+// value can be encoded as JSON.
+func normalizeYAML(v interface{}) interface{} {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(node))
+		for k, val := range node {
+			out[k] = normalizeYAML(val)
+		}
+		return out
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(node))
+		for k, val := range node {
+			out[fmt.Sprintf("%v", k)] = normalizeYAML(val)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(node))
+		for i, val := range node {
+			out[i] = normalizeYAML(val)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func asMap(v interface{}) map[string]interface{} {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		return node
+	case map[interface{}]interface{}:
+		return normalizeYAML(node).(map[string]interface{})
+	default:
+		return nil
+	}
+}
+
+func asSlice(v interface{}) []interface{} {
+	s, _ := v.([]interface{})
+	return s
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
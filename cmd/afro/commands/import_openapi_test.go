@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+const testOpenAPISpec = `
+openapi: 3.0.3
+info:
+  title: Inventory API
+  version: "1.0"
+servers:
+  - url: https://{region}.api.etin.dev/v1/
+    variables:
+      region:
+        default: eu
+paths:
+  /inventories/{inventoryId}/items:
+    parameters:
+      - name: inventoryId
+        in: path
+        required: true
+        schema:
+          type: string
+    get:
+      operationId: listItems
+      parameters:
+        - $ref: '#/components/parameters/Tenant'
+        - name: page
+          in: query
+          schema:
+            type: integer
+    post:
+      operationId: items.create
+      requestBody:
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/Item'
+  /health:
+    get:
+      responses:
+        "200":
+          description: OK
+components:
+  parameters:
+    Tenant:
+      name: X-Tenant
+      in: header
+      required: true
+      schema:
+        type: string
+  schemas:
+    Item:
+      type: object
+      properties:
+        name:
+          type: string
+          example: Box
+        quantity:
+          type: integer
+        tags:
+          type: array
+          items:
+            type: string
+`
+
+func TestParseOpenAPI(t *testing.T) {
+	imported, err := parseOpenAPI([]byte(testOpenAPISpec))
+	if err != nil {
+		t.Fatalf("parseOpenAPI failed: %v", err)
+	}
+
+	if imported.BaseURL != "https://eu.api.etin.dev/v1" {
+		t.Errorf("Unexpected base URL %s", imported.BaseURL)
+	}
+
+	expected := map[string]RequestOptions{
+		"list_items": {
+			Method:  "GET",
+			URL:     "/inventories/{{inventoryId}}/items",
+			Headers: []string{"X-Tenant: {{X-Tenant}}"},
+		},
+		"items_create": {
+			Method:  "POST",
+			URL:     "/inventories/{{inventoryId}}/items",
+			Body:    `{"name":"Box","quantity":0,"tags":["string"]}`,
+			Headers: []string{"Content-Type: application/json"},
+		},
+		"get_health": {
+			Method: "GET",
+			URL:    "/health",
+		},
+	}
+	if !reflect.DeepEqual(imported.Requests, expected) {
+		t.Errorf("Unexpected requests:\n%#v\nexpected:\n%#v", imported.Requests, expected)
+	}
+}
+
+func TestParseOpenAPIRejectsSwagger(t *testing.T) {
+	if _, err := parseOpenAPI([]byte(`{"swagger": "2.0", "paths": {}}`)); err == nil {
+		t.Errorf("Expected error for Swagger 2 document")
+	}
+}
+
+func TestOperationName(t *testing.T) {
+	for id, want := range map[string]string{
+		"getUserById":  "get_user_by_id",
+		"listItems":    "list_items",
+		"items.create": "items_create",
+		"get/health":   "get_health",
+		"HTTPStatus":   "httpstatus",
+		"v2GetUser":    "v2_get_user",
+	} {
+		if got := operationName(id); got != want {
+			t.Errorf("operationName(%q) = %q, want %q", id, got, want)
+		}
+	}
+}
+
+func TestBundleRelativePath(t *testing.T) {
+	dir := t.TempDir()
+	viper.Reset()
+	defer viper.Reset()
+	viper.SetConfigFile(filepath.Join(dir, "bundles", "afro.yaml"))
+
+	t.Chdir(dir)
+
+	if got := bundleRelativePath("specs/api.yaml"); got != filepath.Join("..", "specs", "api.yaml") {
+		t.Errorf("unexpected path %s", got)
+	}
+}
@@ -0,0 +1,361 @@
+package commands
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var importPostmanCmd = &cobra.Command{
+	Use:   "postman [collection.json] [environment.json...]",
+	Short: "Import a Postman collection and environments",
+	Long: `Import a Postman collection (v2.0 or v2.1) as saved requests. Postman
+environment files can be passed alongside the collection, or on their own, and
+are imported as afro environments.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		imported := importedBundle{
+			Requests:     make(map[string]RequestOptions),
+			Environments: make(map[string]map[string]string),
+		}
+
+		for _, path := range args {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: failed to read %s: %v\n", path, err)
+				os.Exit(1)
+			}
+			if err := parsePostman(data, &imported); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s: %v\n", path, err)
+				os.Exit(1)
+			}
+		}
+
+		for name, opts := range imported.Requests {
+			opts.URL = relativeURL(opts.URL, bundleBaseURLs())
+			imported.Requests[name] = opts
+		}
+
+		saveImportedBundle(imported)
+	},
+}
+
+func init() {
+	importCmd.AddCommand(importPostmanCmd)
+}
+
+type postmanCollection struct {
+	Info     struct{ Name string } `json:"info"`
+	Item     []postmanItem         `json:"item"`
+	Auth     *postmanAuth          `json:"auth"`
+	Header   []postmanKeyValue     `json:"header"`
+	Variable []postmanKeyValue     `json:"variable"`
+}
+
+type postmanItem struct {
+	Name    string          `json:"name"`
+	Item    []postmanItem   `json:"item"`
+	Auth    *postmanAuth    `json:"auth"`
+	Request *postmanRequest `json:"request"`
+}
+
+type postmanRequest struct {
+	Method string            `json:"method"`
+	Header []postmanKeyValue `json:"header"`
+	URL    postmanURL        `json:"url"`
+	Auth   *postmanAuth      `json:"auth"`
+	Body   *struct {
+		Mode       string            `json:"mode"`
+		Raw        string            `json:"raw"`
+		URLEncoded []postmanKeyValue `json:"urlencoded"`
+		GraphQL    *struct {
+			Query     string `json:"query"`
+			Variables string `json:"variables"`
+		} `json:"graphql"`
+		Options struct {
+			Raw struct {
+				Language string `json:"language"`
+			} `json:"raw"`
+		} `json:"options"`
+	} `json:"body"`
+}
+
+// postmanURL is either a plain string or an object with a raw URL.
+type postmanURL struct {
+	Raw string `json:"raw"`
+}
+
+func (u *postmanURL) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &u.Raw)
+	}
+	var obj struct {
+		Raw string `json:"raw"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	u.Raw = obj.Raw
+	return nil
+}
+
+type postmanKeyValue struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Type     string `json:"type"`
+	In       string `json:"in"`
+	Disabled bool   `json:"disabled"`
+	// Enabled is only set in environment files.
+	Enabled *bool `json:"enabled"`
+}
+
+func (kv postmanKeyValue) active() bool {
+	return !kv.Disabled && (kv.Enabled == nil || *kv.Enabled)
+}
+
+// postmanAuth holds the attributes of each auth type as key/value lists
+// (collection v2.1) or objects (v2.0).
+type postmanAuth struct {
+	Type   string                     `json:"type"`
+	Params map[string]json.RawMessage `json:"-"`
+}
+
+func (a *postmanAuth) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if t, ok := raw["type"]; ok {
+		if err := json.Unmarshal(t, &a.Type); err != nil {
+			return err
+		}
+	}
+	a.Params = raw
+	return nil
+}
+
+// param returns an attribute of the auth type.
+func (a *postmanAuth) param(name string) string {
+	data, ok := a.Params[a.Type]
+	if !ok {
+		return ""
+	}
+	var list []postmanKeyValue
+	if err := json.Unmarshal(data, &list); err == nil {
+		for _, kv := range list {
+			if kv.Key == name {
+				return kv.Value
+			}
+		}
+		return ""
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(data, &obj); err == nil {
+		if v, ok := obj[name]; ok {
+			return fmt.Sprintf("%v", v)
+		}
+	}
+	return ""
+}
+
+// headers converts the auth into request headers.
+func (a *postmanAuth) headers() []string {
+	if a == nil {
+		return nil
+	}
+	switch a.Type {
+	case "bearer":
+		return []string{"Authorization: Bearer " + a.param("token")}
+	case "basic":
+		credentials := a.param("username") + ":" + a.param("password")
+		if strings.Contains(credentials, "{{") {
+			fmt.Fprintf(os.Stderr, "Warning: basic auth with variables cannot be encoded ahead of time, skipping\n")
+			return nil
+		}
+		return []string{"Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))}
+	case "apikey":
+		if in := a.param("in"); in != "" && in != "header" {
+			fmt.Fprintf(os.Stderr, "Warning: API key auth in %s is not supported, skipping\n", in)
+			return nil
+		}
+		return []string{a.param("key") + ": " + a.param("value")}
+	case "noauth", "":
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "Warning: unsupported Postman auth type '%s', skipping\n", a.Type)
+		return nil
+	}
+}
+
+type postmanEnvironment struct {
+	Name   string            `json:"name"`
+	Values []postmanKeyValue `json:"values"`
+}
+
+var postmanPathVariable = regexp.MustCompile(`/:([A-Za-z0-9_]+)`)
+
+// parsePostman adds the contents of a Postman collection or environment file
+// to imported.
+func parsePostman(data []byte, imported *importedBundle) error {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return fmt.Errorf("failed to parse file: %w", err)
+	}
+
+	if _, ok := probe["values"]; ok {
+		var env postmanEnvironment
+		if err := json.Unmarshal(data, &env); err != nil {
+			return fmt.Errorf("failed to parse environment: %w", err)
+		}
+		name := postmanName(env.Name)
+		if name == "" {
+			return fmt.Errorf("environment has no name")
+		}
+		vars := make(map[string]string)
+		for _, kv := range env.Values {
+			if kv.active() {
+				vars[kv.Key] = kv.Value
+			}
+		}
+		imported.Environments[name] = vars
+		return nil
+	}
+
+	if _, ok := probe["item"]; !ok {
+		return fmt.Errorf("not a Postman collection or environment")
+	}
+
+	var collection postmanCollection
+	if err := json.Unmarshal(data, &collection); err != nil {
+		return fmt.Errorf("failed to parse collection: %w", err)
+	}
+
+	// Collection-level auth and headers apply to every request, like bundle headers.
+	headers := make(map[string]string)
+	for _, h := range append(collection.Auth.headers(), postmanHeaders(collection.Header)...) {
+		parts := strings.SplitN(h, ":", 2)
+		headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	if len(headers) > 0 {
+		imported.Headers = headers
+	}
+
+	for _, v := range collection.Variable {
+		if !v.active() {
+			continue
+		}
+		if imported.Variables == nil {
+			imported.Variables = make(map[string]string)
+		}
+		imported.Variables[v.Key] = v.Value
+	}
+
+	addPostmanItems(collection.Item, "", nil, imported)
+	return nil
+}
+
+func addPostmanItems(items []postmanItem, prefix string, folderAuth *postmanAuth, imported *importedBundle) {
+	for _, item := range items {
+		name := postmanName(item.Name)
+		if prefix != "" {
+			name = prefix + "_" + name
+		}
+
+		auth := folderAuth
+		if item.Auth != nil {
+			auth = item.Auth
+		}
+
+		if item.Request == nil {
+			// A folder: its name prefixes the requests inside it.
+			addPostmanItems(item.Item, name, auth, imported)
+			continue
+		}
+
+		if item.Request.Auth != nil {
+			auth = item.Request.Auth
+		}
+
+		unique := name
+		for i := 2; ; i++ {
+			if _, exists := imported.Requests[unique]; !exists {
+				break
+			}
+			unique = fmt.Sprintf("%s_%d", name, i)
+		}
+		imported.Requests[unique] = postmanRequestOptions(item.Request, auth)
+	}
+}
+
+func postmanRequestOptions(req *postmanRequest, auth *postmanAuth) RequestOptions {
+	opts := RequestOptions{
+		Method: strings.ToUpper(req.Method),
+		URL:    postmanPathVariable.ReplaceAllString(req.URL.Raw, "/{{$1}}"),
+	}
+	if opts.Method == "" {
+		opts.Method = "GET"
+	}
+
+	opts.Headers = append(opts.Headers, auth.headers()...)
+	opts.Headers = append(opts.Headers, postmanHeaders(req.Header)...)
+
+	if req.Body != nil {
+		var contentType string
+		switch req.Body.Mode {
+		case "raw":
+			opts.Body = req.Body.Raw
+			if req.Body.Options.Raw.Language == "json" {
+				contentType = "application/json"
+			}
+		case "urlencoded":
+			var pairs []string
+			for _, kv := range req.Body.URLEncoded {
+				if kv.active() {
+					pairs = append(pairs, url.QueryEscape(kv.Key)+"="+url.QueryEscape(kv.Value))
+				}
+			}
+			opts.Body = strings.Join(pairs, "&")
+			contentType = "application/x-www-form-urlencoded"
+		case "graphql":
+			if req.Body.GraphQL != nil {
+				payload := map[string]interface{}{"query": req.Body.GraphQL.Query}
+				var variables interface{}
+				if err := json.Unmarshal([]byte(req.Body.GraphQL.Variables), &variables); err == nil {
+					payload["variables"] = variables
+				}
+				encoded, _ := json.Marshal(payload)
+				opts.Body = string(encoded)
+				contentType = "application/json"
+			}
+		case "":
+		default:
+			fmt.Fprintf(os.Stderr, "Warning: Postman body mode '%s' is not supported, skipping body\n", req.Body.Mode)
+		}
+		if contentType != "" && opts.Body != "" && !hasHeader(opts.Headers, "Content-Type") {
+			opts.Headers = append(opts.Headers, "Content-Type: "+contentType)
+		}
+	}
+
+	return opts
+}
+
+func postmanHeaders(headers []postmanKeyValue) []string {
+	var converted []string
+	for _, h := range headers {
+		if h.active() && h.Key != "" {
+			converted = append(converted, h.Key+": "+h.Value)
+		}
+	}
+	return converted
+}
+
+// postmanName turns a Postman item or environment name into a request name.
+func postmanName(name string) string {
+	return strings.ToLower(strings.Trim(requestNameReplacer.ReplaceAllString(name, "_"), "_"))
+}
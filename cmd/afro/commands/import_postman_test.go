@@ -0,0 +1,105 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testPostmanCollection = `{
+  "info": {"name": "Inventory", "schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"},
+  "auth": {"type": "bearer", "bearer": [{"key": "token", "value": "{{authToken}}", "type": "string"}]},
+  "variable": [{"key": "baseUrl", "value": "https://api.etin.dev"}],
+  "item": [
+    {
+      "name": "Users",
+      "item": [
+        {
+          "name": "Create user",
+          "request": {
+            "method": "POST",
+            "header": [
+              {"key": "X-Trace", "value": "abc"},
+              {"key": "X-Debug", "value": "1", "disabled": true}
+            ],
+            "url": {"raw": "{{baseUrl}}/users/:teamId?notify=true", "host": ["{{baseUrl}}"]},
+            "body": {"mode": "raw", "raw": "{\"name\": \"{{userName}}\"}", "options": {"raw": {"language": "json"}}}
+          }
+        }
+      ]
+    },
+    {
+      "name": "Login",
+      "request": {
+        "method": "POST",
+        "auth": {"type": "basic", "basic": [{"key": "username", "value": "ada"}, {"key": "password", "value": "secret"}]},
+        "url": "{{baseUrl}}/login",
+        "body": {"mode": "urlencoded", "urlencoded": [{"key": "remember", "value": "yes please"}]}
+      }
+    }
+  ]
+}`
+
+const testPostmanEnvironment = `{
+  "name": "Staging Env",
+  "values": [
+    {"key": "baseUrl", "value": "https://staging.api.etin.dev", "enabled": true},
+    {"key": "unused", "value": "x", "enabled": false}
+  ],
+  "_postman_variable_scope": "environment"
+}`
+
+func TestParsePostman(t *testing.T) {
+	imported := importedBundle{
+		Requests:     make(map[string]RequestOptions),
+		Environments: make(map[string]map[string]string),
+	}
+	if err := parsePostman([]byte(testPostmanCollection), &imported); err != nil {
+		t.Fatalf("parsePostman (collection) failed: %v", err)
+	}
+	if err := parsePostman([]byte(testPostmanEnvironment), &imported); err != nil {
+		t.Fatalf("parsePostman (environment) failed: %v", err)
+	}
+
+	expectedRequests := map[string]RequestOptions{
+		"users_create_user": {
+			Method:  "POST",
+			URL:     "{{baseUrl}}/users/{{teamId}}?notify=true",
+			Body:    `{"name": "{{userName}}"}`,
+			Headers: []string{"X-Trace: abc", "Content-Type: application/json"},
+		},
+		"login": {
+			Method:  "POST",
+			URL:     "{{baseUrl}}/login",
+			Body:    "remember=yes+please",
+			Headers: []string{"Authorization: Basic YWRhOnNlY3JldA==", "Content-Type: application/x-www-form-urlencoded"},
+		},
+	}
+	if !reflect.DeepEqual(imported.Requests, expectedRequests) {
+		t.Errorf("Unexpected requests:\n%#v\nexpected:\n%#v", imported.Requests, expectedRequests)
+	}
+
+	if got := imported.Headers["Authorization"]; got != "Bearer {{authToken}}" {
+		t.Errorf("Expected collection auth as bundle header, got %q", got)
+	}
+	if got := imported.Variables["baseUrl"]; got != "https://api.etin.dev" {
+		t.Errorf("Expected collection variable, got %q", got)
+	}
+
+	expectedEnv := map[string]string{"baseUrl": "https://staging.api.etin.dev"}
+	if !reflect.DeepEqual(imported.Environments["staging_env"], expectedEnv) {
+		t.Errorf("Unexpected environments %v", imported.Environments)
+	}
+}
+
+func TestLowerPlaceholders(t *testing.T) {
+	opts := lowerPlaceholders(RequestOptions{
+		URL:     "{{baseUrl}}/users/{{teamId}}?at={{$timestamp}}",
+		Headers: []string{"X-Token: {{authToken}}"},
+	})
+	if opts.URL != "{{baseurl}}/users/{{teamid}}?at={{$timestamp}}" {
+		t.Errorf("Unexpected URL %s", opts.URL)
+	}
+	if opts.Headers[0] != "X-Token: {{authtoken}}" {
+		t.Errorf("Unexpected header %s", opts.Headers[0])
+	}
+}
@@ -61,11 +61,7 @@ func runInit() {
 	}
 
 	// Save config
-	filename := "afro.yaml"
-	bundle, _ := rootCmd.Flags().GetString("bundle")
-	if bundle != "" {
-		filename = bundle + ".yaml"
-	}
+	filename := bundleFilename()
 
 	err := viper.WriteConfigAs(filename)
 	if err != nil {
@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"afro/pkg/chain"
+)
+
+// reportTarget is a report format and the file it is written to.
+type reportTarget struct {
+	format string
+	path   string
+}
+
+// parseReportTargets parses --report values of the form format=path.
+func parseReportTargets(specs []string) ([]reportTarget, error) {
+	var targets []reportTarget
+	for _, spec := range specs {
+		format, path, ok := strings.Cut(spec, "=")
+		format = strings.ToLower(strings.TrimSpace(format))
+		path = strings.TrimSpace(path)
+		if !ok || path == "" {
+			return nil, fmt.Errorf("invalid report '%s', expected format=path", spec)
+		}
+		if format != "junit" && format != "json" {
+			return nil, fmt.Errorf("unknown report format '%s', expected junit or json", format)
+		}
+		targets = append(targets, reportTarget{format: format, path: path})
+	}
+	return targets, nil
+}
+
+// writeReports writes the report to every target.
+func writeReports(report *chain.Report, targets []reportTarget) error {
+	for _, target := range targets {
+		f, err := os.Create(target.path)
+		if err != nil {
+			return fmt.Errorf("failed to create report: %w", err)
+		}
+		if target.format == "junit" {
+			err = report.WriteJUnit(f)
+		} else {
+			err = report.WriteJSON(f)
+		}
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write report '%s': %w", target.path, err)
+		}
+	}
+	return nil
+}
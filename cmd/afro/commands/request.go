@@ -4,9 +4,9 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
-	"strings"
+
+	"afro/pkg/chain"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -16,6 +16,73 @@ func addRequestFlags(cmd *cobra.Command) {
 	cmd.Flags().StringP("body", "b", "", "Request body (string or file path)")
 	cmd.Flags().StringSliceP("header", "H", []string{}, "Request headers (e.g. \"Content-Type: application/json\")")
 	cmd.Flags().String("save", "", "Save the request with the given name")
+	addOutputFlags(cmd)
+}
+
+func addOutputFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolP("include", "i", false, "Print the response status line and headers before the body")
+	cmd.Flags().BoolP("verbose", "v", false, "Also print the outgoing request line and headers to stderr")
+	cmd.Flags().Bool("raw", false, "Print response bodies exactly as received")
+	cmd.Flags().String("format", "", "Convert response bodies to json, yaml or raw")
+	cmd.Flags().String("select", "", "Print only the part of the response matching a JSONPath (e.g. '$.data[*].id')")
+}
+
+// OutputOptions holds the options for printing responses
+type OutputOptions struct {
+	Include bool
+	Verbose bool
+	Raw     bool
+	Format  string
+	Select  string
+}
+
+func buildOutputOptions(cmd *cobra.Command) OutputOptions {
+	include, _ := cmd.Flags().GetBool("include")
+	verbose, _ := cmd.Flags().GetBool("verbose")
+	raw, _ := cmd.Flags().GetBool("raw")
+	format, _ := cmd.Flags().GetString("format")
+	selectPath, _ := cmd.Flags().GetString("select")
+
+	return OutputOptions{
+		Include: include,
+		Verbose: verbose,
+		Raw:     raw,
+		Format:  format,
+		Select:  selectPath,
+	}
+}
+
+// apply configures how the runner prints responses. JSON is pretty-printed
+// and colorized by default when writing to a terminal.
+func (o OutputOptions) apply(runner *chain.Runner) error {
+	runner.Include = o.Include
+	runner.Verbose = o.Verbose
+	runner.Select = o.Select
+
+	tty := isTerminal(runner.Out)
+	switch {
+	case o.Raw:
+		runner.Format = chain.FormatRaw
+	case o.Format != "":
+		format, err := chain.ParseFormat(o.Format)
+		if err != nil {
+			return err
+		}
+		runner.Format = format
+	case tty:
+		runner.Format = chain.FormatAuto
+	}
+	runner.Color = tty && !o.Raw && os.Getenv("NO_COLOR") == ""
+	return nil
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
 }
 
 // RequestOptions holds the options for making a request
@@ -26,6 +93,18 @@ type RequestOptions struct {
 	Headers   []string
 	NoHeaders bool
 	SaveName  string
+	Output    OutputOptions
+}
+
+// request converts the options into a request the chain runner can execute.
+func (opts RequestOptions) request() chain.Request {
+	return chain.Request{
+		Method:    opts.Method,
+		URL:       opts.URL,
+		Body:      opts.Body,
+		Headers:   opts.Headers,
+		NoHeaders: opts.NoHeaders,
+	}
 }
 
 func buildRequestOptions(method string, args []string, cmd *cobra.Command) RequestOptions {
@@ -41,10 +120,24 @@ func buildRequestOptions(method string, args []string, cmd *cobra.Command) Reque
 		Headers:   headers,
 		NoHeaders: noHeaders,
 		SaveName:  saveName,
+		Output:    buildOutputOptions(cmd),
 	}
 }
 
 func saveRequest(opts RequestOptions, name string) {
+	setRequest(opts, name)
+
+	// Save the config
+	filename, err := writeBundle()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save request: %v\n", err)
+		return
+	}
+	fmt.Printf("Request saved as '%s' to %s\n", name, filename)
+}
+
+// setRequest stores the request in the active config without writing it.
+func setRequest(opts RequestOptions, name string) {
 	// Structure: requests.<name>
 	key := fmt.Sprintf("requests.%s", name)
 	viper.Set(key+".method", opts.Method)
@@ -56,67 +149,18 @@ func saveRequest(opts RequestOptions, name string) {
 		viper.Set(key+".headers", opts.Headers)
 	}
 	viper.Set(key+".no_headers", opts.NoHeaders)
-
-	// Save the config
-	if err := viper.WriteConfig(); err != nil {
-		// Fallback or explicit write?
-		if viper.ConfigFileUsed() == "" {
-			err = viper.WriteConfigAs("afro.yaml")
-		}
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to save request: %v\n", err)
-		} else {
-			fmt.Printf("Request saved as '%s' to afro.yaml\n", name)
-		}
-	} else {
-		fmt.Printf("Request saved as '%s' to %s\n", name, viper.ConfigFileUsed())
-	}
 }
 
 func makeRequest(ctx context.Context, opts RequestOptions, out io.Writer) (int, error) {
-	// Determine URL
-	url := opts.URL
-	baseURL := viper.GetString("base_url")
-
-	// If URL does not start with http(s), prepend base URL
-	if !strings.HasPrefix(url, "http") && baseURL != "" {
-		if !strings.HasSuffix(baseURL, "/") && !strings.HasPrefix(url, "/") {
-			url = baseURL + "/" + url
-		} else if strings.HasSuffix(baseURL, "/") && strings.HasPrefix(url, "/") {
-			url = baseURL + strings.TrimPrefix(url, "/")
-		} else {
-			url = baseURL + url
-		}
-	} else if !strings.HasPrefix(url, "http") {
+	runner, err := newRunner()
+	if err != nil {
+		return 0, err
 	}
-
-	var reqBody io.Reader
-	if opts.Body != "" {
-		if strings.HasPrefix(opts.Body, "@") {
-			// Explicit file path
-			filePath := strings.TrimPrefix(opts.Body, "@")
-			f, err := os.Open(filePath)
-			if err != nil {
-				return 0, fmt.Errorf("failed to open body file: %w", err)
-			}
-			defer f.Close()
-			reqBody = f
-		} else if _, err := os.Stat(opts.Body); err == nil {
-			f, err := os.Open(opts.Body)
-			if err != nil {
-				return 0, fmt.Errorf("failed to open body file: %w", err)
-			}
-			defer f.Close()
-			reqBody = f
-		} else {
-			// It's a string
-			reqBody = strings.NewReader(opts.Body)
-		}
+	if out != nil {
+		runner.Out = out
 	}
-
-	req, err := http.NewRequestWithContext(ctx, opts.Method, url, reqBody)
-	if err != nil {
-		return 0, fmt.Errorf("failed to create request: %w", err)
+	if err := opts.Output.apply(runner); err != nil {
+		return 0, err
 	}
 
 	// Save request if requested
@@ -124,40 +168,14 @@ func makeRequest(ctx context.Context, opts RequestOptions, out io.Writer) (int,
 		saveRequest(opts, opts.SaveName)
 	}
 
-	// Add Headers
-	if !opts.NoHeaders {
-		headers := viper.GetStringMapString("headers")
-		for k, v := range headers {
-			req.Header.Add(k, v)
-		}
-	}
-
-	for _, h := range opts.Headers {
-		parts := strings.SplitN(h, ":", 2)
-		if len(parts) == 2 {
-			key := strings.TrimSpace(parts[0])
-			val := strings.TrimSpace(parts[1])
-			req.Header.Set(key, val)
-		}
-	}
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	statusCode, err := runner.Do(ctx, opts.request())
 	if err != nil {
-		return 0, fmt.Errorf("request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if out == nil {
-		out = os.Stdout
-	}
-	if _, err := io.Copy(out, resp.Body); err != nil {
-		return resp.StatusCode, fmt.Errorf("failed to read body: %w", err)
+		return statusCode, err
 	}
 	// Ensure newline at the end for friendliness in CLI if writing to stdout
-	if out == os.Stdout {
+	if out == nil || out == os.Stdout {
 		fmt.Println()
 	}
 
-	return resp.StatusCode, nil
+	return statusCode, nil
 }
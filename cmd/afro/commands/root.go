@@ -3,12 +3,16 @@ package commands
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var cfgFile string
+var envName string
+var timeout time.Duration
+var retries int
 
 var rootCmd = &cobra.Command{
 	Use:   "afro",
@@ -30,6 +34,9 @@ func init() {
 	// Global flags
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is ./afro.yaml)")
 	rootCmd.PersistentFlags().String("bundle", "", "specify what bundle to use for the command")
+	rootCmd.PersistentFlags().StringVar(&envName, "env", "", "environment from the bundle to use for the command")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "timeout for each request attempt, e.g. 10s (overrides the bundle)")
+	rootCmd.PersistentFlags().IntVar(&retries, "retries", 0, "number of times to retry a failed request (overrides the bundle)")
 }
 
 func initConfig() {
@@ -0,0 +1,242 @@
+package chain
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// operand is one side of an assertion. A lone {{variable}} keeps the
+// variable's raw value, so arrays, objects and numbers can be checked as such;
+// anything else is the substituted text.
+type operand struct {
+	text  string
+	value interface{}
+	// raw is set when value holds a variable's value.
+	raw bool
+	// name is the variable a lone placeholder or bare word refers to.
+	name string
+	set  bool
+	// err is why a value could not be read from the response.
+	err error
+}
+
+func resolveOperand(tmpl string, vars map[string]interface{}) operand {
+	trimmed := strings.TrimSpace(tmpl)
+	if m := placeholderPattern.FindStringSubmatch(trimmed); m != nil && m[0] == trimmed {
+		name := strings.TrimSpace(m[1])
+		if v, ok := lookupVariable(vars, name); ok {
+			return operand{text: fmt.Sprintf("%v", v), value: v, raw: true, name: name, set: true}
+		}
+		return operand{text: substitute(tmpl, vars), name: name}
+	}
+	o := textOperand(substitute(tmpl, vars))
+	if !strings.Contains(trimmed, "{{") {
+		o.name = trimmed
+		_, o.set = lookupVariable(vars, trimmed)
+	}
+	return o
+}
+
+// responseSource returns the extraction source the left side of an assertion
+// names when it refers to the response, accepting header.<name> for
+// header:<name>.
+func responseSource(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	switch s {
+	case "status", "body", "duration_ms", "body_size":
+		return s, true
+	}
+	if name, ok := strings.CutPrefix(s, "header."); ok {
+		return "header:" + name, true
+	}
+	if prefix, _, ok := strings.Cut(s, ":"); ok {
+		switch prefix {
+		case "header", "cookie", "jsonpath", "xpath", "regex":
+			return s, true
+		}
+	}
+	return "", false
+}
+
+// operand reads an assertion operand from the response.
+func (d *responseDocument) operand(source string) operand {
+	v, err := d.extract(source)
+	if err != nil {
+		return operand{name: source, err: err}
+	}
+	return operand{text: fmt.Sprintf("%v", v), value: v, raw: true, name: source, set: true}
+}
+
+func textOperand(s string) operand {
+	return operand{text: s, value: s}
+}
+
+// display shows the operand in failure messages: raw values other than
+// strings as JSON, values missing from the response by their source, and
+// everything else as text.
+func (o operand) display() string {
+	if o.err != nil {
+		return o.name
+	}
+	if !o.raw {
+		return o.text
+	}
+	if s, ok := o.value.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(o.value)
+	if err != nil {
+		return o.text
+	}
+	return string(b)
+}
+
+// list returns the items of an operand for 'in': an array value, a JSON array,
+// or comma-separated text.
+func (o operand) list() []string {
+	items, ok := o.value.([]interface{})
+	if !ok {
+		if err := json.Unmarshal([]byte(o.text), &items); err != nil {
+			var out []string
+			for _, item := range strings.Split(o.text, ",") {
+				out = append(out, strings.TrimSpace(item))
+			}
+			return out
+		}
+	}
+	out := make([]string, len(items))
+	for i, item := range items {
+		out[i] = fmt.Sprintf("%v", item)
+	}
+	return out
+}
+
+// jsonType returns the JSON type of a raw value, or "string" for text.
+func (o operand) jsonType() string {
+	switch o.value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64, float32, int, int64:
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return "string"
+	}
+}
+
+func (o operand) length() (int, error) {
+	switch v := o.value.(type) {
+	case []interface{}:
+		return len(v), nil
+	case map[string]interface{}:
+		return len(v), nil
+	case nil:
+		return 0, nil
+	case string:
+		return utf8.RuneCountInString(v), nil
+	default:
+		return 0, fmt.Errorf("'%s' has no length", o.display())
+	}
+}
+
+var jsonTypes = map[string]bool{"string": true, "number": true, "boolean": true, "array": true, "object": true, "null": true}
+
+// evaluate applies an assertion operator. Besides the comparisons these are
+// contains, not_contains, matches, starts_with, in, exists, not_exists,
+// is_type, and length followed by a comparison, e.g. 'length >='.
+func evaluate(left operand, op string, right operand) (bool, error) {
+	op = strings.TrimSpace(op)
+	// A value missing from the response only satisfies not_exists.
+	if left.err != nil && op != "exists" && op != "not_exists" {
+		return false, nil
+	}
+
+	if cmp, ok := strings.CutPrefix(op, "length "); ok {
+		n, err := left.length()
+		if err != nil {
+			return false, err
+		}
+		length := operand{text: strconv.Itoa(n), value: float64(n), raw: true}
+		return evaluate(length, strings.TrimSpace(cmp), right)
+	}
+
+	switch op {
+	case "contains", "not_contains":
+		found := false
+		switch v := left.value.(type) {
+		case []interface{}:
+			for _, item := range v {
+				if fmt.Sprintf("%v", item) == right.text {
+					found = true
+					break
+				}
+			}
+		case map[string]interface{}:
+			_, found = v[right.text]
+		default:
+			found = strings.Contains(left.text, right.text)
+		}
+		return found == (op == "contains"), nil
+	case "matches":
+		re, err := regexp.Compile(right.text)
+		if err != nil {
+			return false, fmt.Errorf("invalid pattern '%s': %w", right.text, err)
+		}
+		return re.MatchString(left.text), nil
+	case "starts_with":
+		return strings.HasPrefix(left.text, right.text), nil
+	case "in":
+		for _, item := range right.list() {
+			if item == left.text {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "exists", "not_exists":
+		if left.name == "" {
+			return false, fmt.Errorf("'%s' needs a variable name", op)
+		}
+		return left.set == (op == "exists"), nil
+	case "is_type":
+		want := strings.ToLower(strings.TrimSpace(right.text))
+		if !jsonTypes[want] {
+			return false, fmt.Errorf("unknown type '%s', expected string, number, boolean, array, object or null", right.text)
+		}
+		return left.jsonType() == want, nil
+	case "==", "!=":
+		// Text such as "00123" and "123" differs, but a JSON number equals
+		// any spelling of its value, such as 3.0 for 3.
+		if l, r, ok := numbers(left, right); ok {
+			return (l == r) == (op == "=="), nil
+		}
+		return compare(left.text, op, right.text)
+	default:
+		return compare(left.text, op, right.text)
+	}
+}
+
+// numbers returns the values of two operands for comparing them as numbers,
+// which they are when one of them is a JSON number and the other is a number
+// too.
+func numbers(left, right operand) (float64, float64, bool) {
+	if !left.isNumber() && !right.isNumber() {
+		return 0, 0, false
+	}
+	l, errL := strconv.ParseFloat(strings.TrimSpace(left.text), 64)
+	r, errR := strconv.ParseFloat(strings.TrimSpace(right.text), 64)
+	return l, r, errL == nil && errR == nil
+}
+
+// isNumber reports whether the operand holds a JSON number rather than text.
+func (o operand) isNumber() bool {
+	return o.raw && o.jsonType() == "number"
+}
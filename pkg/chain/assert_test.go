@@ -0,0 +1,221 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestAssertionOperators(t *testing.T) {
+	vars := map[string]interface{}{
+		"price":   1.5,
+		"count":   float64(3),
+		"name":    "Ada Lovelace",
+		"roles":   []interface{}{"admin", "user"},
+		"user":    map[string]interface{}{"id": float64(1)},
+		"status":  201,
+		"nothing": nil,
+		"zip":     "00123",
+	}
+
+	tests := []struct {
+		left, op, right string
+		want            bool
+	}{
+		{"{{price}}", ">", "1.25", true},
+		{"{{price}}", "<=", "1.25", false},
+		{"{{count}}", "==", "3.0", true},
+		{"{{count}}", "!=", "3", false},
+		{"00123", "==", "123", false},
+		{"1e3", "==", "1000", false},
+		{"1.0", "!=", "1", true},
+		{"{{zip}}", "==", "123", false},
+		{"{{name}}", "contains", "Love", true},
+		{"{{name}}", "not_contains", "Babbage", true},
+		{"{{roles}}", "contains", "admin", true},
+		{"{{roles}}", "contains", "adm", false},
+		{"{{user}}", "contains", "id", true},
+		{"{{name}}", "matches", `^Ada \w+$`, true},
+		{"{{name}}", "starts_with", "Ada", true},
+		{"{{status}}", "in", "200, 201, 204", true},
+		{"admin", "in", `["admin","owner"]`, true},
+		{"guest", "in", "{{roles}}", false},
+		{"{{name}}", "exists", "", true},
+		{"token", "exists", "", false},
+		{"{{token}}", "not_exists", "", true},
+		{"{{nothing}}", "exists", "", true},
+		{"{{name}}", "is_type", "string", true},
+		{"{{count}}", "is_type", "number", true},
+		{"{{roles}}", "is_type", "array", true},
+		{"{{user}}", "is_type", "object", true},
+		{"{{nothing}}", "is_type", "null", true},
+		{"{{status}}", "is_type", "string", false},
+		{"{{roles}}", "length ==", "2", true},
+		{"{{name}}", "length >", "20", false},
+	}
+
+	for _, tt := range tests {
+		results, err := checkAssertions([]Assertion{{Left: tt.left, Op: tt.op, Right: tt.right}}, vars, nil)
+		if tt.want && err != nil {
+			t.Errorf("%s %s %s: unexpected error %v", tt.left, tt.op, tt.right, err)
+		}
+		if !tt.want && err == nil {
+			t.Errorf("%s %s %s: expected failure", tt.left, tt.op, tt.right)
+		}
+		if len(results) != 1 || results[0].Passed != tt.want {
+			t.Errorf("%s %s %s: unexpected results %+v", tt.left, tt.op, tt.right, results)
+		}
+	}
+}
+
+func TestAssertionFailureShowsRawValue(t *testing.T) {
+	vars := map[string]interface{}{"roles": []interface{}{"admin", "user"}}
+
+	_, err := checkAssertions([]Assertion{{Left: "{{roles}}", Op: "contains", Right: "owner"}}, vars, nil)
+	if err == nil || !strings.Contains(err.Error(), `'["admin","user"]' contains 'owner'`) {
+		t.Errorf("expected the raw JSON value in the error, got %v", err)
+	}
+
+	_, err = checkAssertions([]Assertion{{Left: "{{roles}}", Op: "is_type", Right: "list"}}, vars, nil)
+	if err == nil || !strings.Contains(err.Error(), "unknown type 'list'") {
+		t.Errorf("expected an unknown type error, got %v", err)
+	}
+}
+
+func TestShellTest(t *testing.T) {
+	for _, tool := range []string{"jq", "perl"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not installed", tool)
+		}
+	}
+
+	script := `set -euo pipefail
+name="Ada Lovelace"; roles='["admin","user"]'; price=1.5
+`
+	tests := []struct {
+		left, op, right string
+		want            bool
+	}{
+		{"{{name}}", "contains", "Love", true},
+		{"{{roles}}", "contains", "adm", false},
+		{"{{roles}}", "not_contains", "owner", true},
+		{"{{name}}", "matches", `^Ada \w+$`, true},
+		{"{{name}}", "starts_with", "Ada", true},
+		{"admin", "in", "{{roles}}", true},
+		{"204", "in", "200, 201", false},
+		{"{{name}}", "exists", "", true},
+		{"{{token}}", "not_exists", "", true},
+		{"{{roles}}", "is_type", "array", true},
+		{"{{roles}}", "length ==", "2", true},
+		{"{{name}}", "matches", `^Ada \d+$`, false},
+		// Numbers are compared as decimals, and anything else as text.
+		{"{{price}}", ">", "1.25", true},
+		{"{{price}}", "<=", "1.50", true},
+		{"10", ">", "9", true},
+		{"abc", "<", "abd", true},
+		{"{{roles}}", "length <", "2.5", true},
+		{"{{price}}", "==", "1.50", true},
+		{"{{price}}", "!=", "1.5", false},
+		{"{{name}}", "==", "Ada Lovelace", true},
+		{"{{name}}", "!=", "Ada", true},
+	}
+	for _, tt := range tests {
+		test, err := shellTest(tt.left, tt.op, tt.right, nil)
+		if err != nil {
+			t.Fatalf("shellTest(%s %s %s) failed: %v", tt.left, tt.op, tt.right, err)
+		}
+		err = exec.Command("bash", "-c", script+test).Run()
+		if (err == nil) != tt.want {
+			t.Errorf("%s: expected %v, got error %v", test, tt.want, err)
+		}
+	}
+}
+
+func TestResponseAssertions(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"items":[{"id":7}]}`)
+	}))
+	defer ts.Close()
+
+	bundle := &Bundle{
+		BaseURL:  ts.URL,
+		Requests: map[string]Request{"create": {Method: "POST", URL: "/items"}},
+		Chains: map[string][]ChainStep{
+			"flow": {{
+				Request: "create",
+				Assert: []Assertion{
+					{Left: "status", Op: "==", Right: "201"},
+					{Left: "header.Content-Type", Op: "contains", Right: "json"},
+					{Left: "jsonpath:$.items[0].id", Op: "==", Right: "7"},
+					{Left: "jsonpath:$.items", Op: "length ==", Right: "1"},
+					{Left: "duration_ms", Op: "<", Right: "5000"},
+					{Left: "body_size", Op: "==", Right: "20"},
+					{Left: "header.X-Request-Id", Op: "not_exists"},
+				},
+			}},
+			"missing": {{
+				Request: "create",
+				Assert:  []Assertion{{Left: "header.X-Request-Id", Op: "==", Right: "abc"}},
+			}},
+		},
+	}
+
+	runner := New(bundle)
+	runner.Out = &bytes.Buffer{}
+	runner.Log = nil
+
+	if err := runner.Run(context.Background(), "flow"); err != nil {
+		t.Fatalf("expected the assertions to pass, got %v", err)
+	}
+
+	err := runner.Run(context.Background(), "missing")
+	if err == nil || !strings.Contains(err.Error(), "assertion 1 failed: header 'X-Request-Id' not found") {
+		t.Errorf("expected a missing header error, got %v", err)
+	}
+}
+
+func TestCurlScriptResponseAssertions(t *testing.T) {
+	bundle := &Bundle{
+		BaseURL:  "https://api.etin.dev",
+		Requests: map[string]Request{"create": {Method: "POST", URL: "/items"}},
+		Chains: map[string][]ChainStep{
+			"flow": {{
+				Request: "create",
+				Assert: []Assertion{
+					{Left: "status", Op: "==", Right: "201"},
+					{Left: "header.Location", Op: "exists"},
+				},
+			}},
+			"timed": {{
+				Request: "create",
+				Assert:  []Assertion{{Left: "duration_ms", Op: "<", Right: "300"}},
+			}},
+		},
+	}
+
+	runner := New(bundle)
+	got, err := runner.CurlScript("flow")
+	if err != nil {
+		t.Fatalf("CurlScript failed: %v", err)
+	}
+	for _, want := range []string{
+		`-D "$headers"`,
+		`{ actual="$status"; jq -en --arg l "${actual}" --arg r "201" '[$l, $r | try tonumber catch null] as [$a, $b] | if $a != null and $b != null then $a == $b else $l == $r end' >/dev/null; } || {`,
+		`{ actual=$({ grep -i '^Location:' "$headers" || true; } | tail -n 1 | cut -d ' ' -f 2- | tr -d '\r'); [ -n "${actual}" ]; } || {`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected script to contain %q, got:\n%s", want, got)
+		}
+	}
+
+	if _, err := runner.CurlScript("timed"); err == nil || !strings.Contains(err.Error(), "'duration_ms' cannot be exported") {
+		t.Errorf("expected duration_ms not to be exported, got %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package chain
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Bundle is a collection of related requests and the chains that link them.
+type Bundle struct {
+	BaseURL      string                 `mapstructure:"base_url"`
+	Headers      map[string]string      `mapstructure:"headers"`
+	Requests     map[string]Request     `mapstructure:"requests"`
+	Chains       map[string][]ChainStep `mapstructure:"chains"`
+	Environments map[string]Environment `mapstructure:"environments"`
+	// Variables are defaults for every environment.
+	Variables map[string]string `mapstructure:"variables"`
+	// Timeout and Retry apply to every request that does not set its own.
+	Timeout time.Duration `mapstructure:"timeout"`
+	Retry   *RetryPolicy  `mapstructure:"retry"`
+	// ChainOptions holds the settings of chains written as a mapping with
+	// their steps under `steps:`.
+	ChainOptions map[string]ChainOptions `mapstructure:"-"`
+	// OpenAPI is the path of the OpenAPI document the bundle was imported
+	// from. Step schemas such as #/components/schemas/User point into it.
+	OpenAPI string `mapstructure:"openapi"`
+	// Dir is the directory of the bundle file. Relative paths in the bundle,
+	// such as schema files and OpenAPI, are resolved against it, and
+	// snapshots are stored in it. It defaults to the working directory.
+	Dir string `mapstructure:"-"`
+}
+
+// path resolves a path given in the bundle against its directory.
+func (b *Bundle) path(p string) string {
+	if b.Dir == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(b.Dir, p)
+}
+
+// ChainOptions are the settings of a single chain.
+type ChainOptions struct {
+	// Strict, when set, overrides the runner's Strict for this chain.
+	Strict *bool `mapstructure:"strict"`
+	// Outputs are the variables exported to a chain that runs this one as a
+	// step, unless the step lists its own.
+	Outputs []string `mapstructure:"outputs"`
+}
+
+// Environment overrides the bundle's base URL and headers and defines variables
+// usable as {{var}} in requests and chains.
+type Environment struct {
+	BaseURL   string            `mapstructure:"base_url"`
+	Headers   map[string]string `mapstructure:"headers"`
+	Variables map[string]string `mapstructure:"variables"`
+}
+
+// LookupRequest returns the saved request with the given name. Names are
+// matched case-insensitively, since viper lowercases those read from a config
+// file.
+func (b *Bundle) LookupRequest(name string) (Request, bool) {
+	key, ok := lookupName(b.Requests, name)
+	return b.Requests[key], ok
+}
+
+// LookupChain returns the steps of the named chain, matching the name like
+// LookupRequest.
+func (b *Bundle) LookupChain(name string) ([]ChainStep, bool) {
+	key, ok := lookupName(b.Chains, name)
+	return b.Chains[key], ok
+}
+
+// lookupName returns the key of m that name refers to, preferring an exact
+// match over one that only differs in case.
+func lookupName[V any](m map[string]V, name string) (string, bool) {
+	if _, ok := m[name]; ok {
+		return name, true
+	}
+	for key := range m {
+		if strings.EqualFold(key, name) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// Request describes a single HTTP request, either saved in a bundle or made ad hoc.
+type Request struct {
+	Method    string   `mapstructure:"method"`
+	URL       string   `mapstructure:"url"`
+	Body      string   `mapstructure:"body"`
+	Headers   []string `mapstructure:"headers"`
+	NoHeaders bool     `mapstructure:"no_headers"`
+	// Timeout limits each attempt, including reading the response body.
+	Timeout time.Duration `mapstructure:"timeout"`
+	Retry   *RetryPolicy  `mapstructure:"retry"`
+}
+
+// Assertion compares two values after variable substitution. A lone
+// {{variable}} keeps its extracted value, so operators such as contains,
+// is_type and length can check arrays and objects.
+type Assertion struct {
+	Left  string `mapstructure:"left"`
+	Op    string `mapstructure:"op"`
+	Right string `mapstructure:"right"`
+	// Soft assertions do not stop the chain when they fail. The run still
+	// fails once all of its steps have run.
+	Soft bool `mapstructure:"soft"`
+}
+
+// ChainStep is a single step of a chain.
+type ChainStep struct {
+	Request string            `mapstructure:"request"`
+	Extract map[string]string `mapstructure:"extract"`
+	// Chain runs another chain as this step, with Variables as its inputs.
+	// Only the variables named in Outputs, or the chain's declared outputs,
+	// are set in the calling chain afterwards.
+	Chain   string   `mapstructure:"chain"`
+	Outputs []string `mapstructure:"outputs"`
+	// OnStatus branches on the response status. Keys are exact codes, ranges
+	// such as 400-499, wildcards such as 5xx, or default; the most specific
+	// matching key wins.
+	OnStatus map[string][]ChainStep `mapstructure:"on_status"`
+	// OnError runs instead of failing the chain when the request gets no
+	// response, with the error available as {{error}}.
+	OnError   []ChainStep       `mapstructure:"on_error"`
+	Assert    []Assertion       `mapstructure:"assert"`
+	Variables map[string]string `mapstructure:"variables"`
+	// Parallel makes this step a group whose steps run concurrently, at most
+	// MaxConcurrency at a time when it is set.
+	Parallel       []ChainStep `mapstructure:"parallel"`
+	MaxConcurrency int         `mapstructure:"max_concurrency"`
+	// Foreach names a variable holding an array and runs the step once per
+	// item, binding {{item}} and {{index}}. Repeat runs it a fixed number of
+	// times instead. When Steps is set they are run on each iteration rather
+	// than the step's own request, or once for a step without a loop.
+	Foreach string      `mapstructure:"foreach"`
+	Repeat  int         `mapstructure:"repeat"`
+	Steps   []ChainStep `mapstructure:"steps"`
+	// Collect gathers a variable set on each iteration into an array, keyed by
+	// the name of the array variable.
+	Collect map[string]string `mapstructure:"collect"`
+	// If is an expression over variables that decides whether the step runs.
+	// When it is false the Else steps run instead.
+	If   string      `mapstructure:"if"`
+	Else []ChainStep `mapstructure:"else"`
+	// Until re-runs the step until its assertions pass.
+	Until *Poll `mapstructure:"until"`
+	// ContinueOnError carries on with the next step when this one fails, and
+	// fails the run once all of its steps have run.
+	ContinueOnError bool `mapstructure:"continue_on_error"`
+	// Timeout and Retry override those of the request for this step only.
+	Timeout time.Duration `mapstructure:"timeout"`
+	Retry   *RetryPolicy  `mapstructure:"retry"`
+	// Schema is a JSON Schema (draft 2020-12) the JSON response body must
+	// match. It is written inline as YAML or JSON, or references a file as
+	// path or path#/pointer, or with only #/pointer the OpenAPI document.
+	Schema string `mapstructure:"schema"`
+	// Snapshot compares the response body with the one stored by an
+	// earlier run.
+	Snapshot *Snapshot `mapstructure:"snapshot"`
+}
@@ -0,0 +1,748 @@
+package chain
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CurlCommand renders the named saved request as a runnable curl command, with
+// the base URL, bundle headers and variables resolved.
+func (r *Runner) CurlCommand(name string) (string, error) {
+	saved, ok := r.Bundle.LookupRequest(name)
+	if !ok {
+		return "", fmt.Errorf("request '%s' not found in config", name)
+	}
+
+	vars, err := r.initialVariables()
+	if err != nil {
+		return "", err
+	}
+	prepared, err := r.prepare(saved, vars)
+	if err != nil {
+		return "", err
+	}
+
+	retryArgs, err := r.curlRetryArgs(saved)
+	if err != nil {
+		return "", fmt.Errorf("request '%s': %w", name, err)
+	}
+	command := append([]string{"curl"}, curlMethodArgs(prepared)...)
+	command = append(command, retryArgs...)
+	command = append(command, shellQuote(prepared.URL))
+	args := []string{strings.Join(command, " ")}
+	for _, key := range sortedHeaderKeys(prepared.Header) {
+		for _, v := range prepared.Header[key] {
+			args = append(args, "-H "+shellQuote(key+": "+v))
+		}
+	}
+	if prepared.BodyFile != "" {
+		args = append(args, "--data-binary "+shellQuote("@"+prepared.BodyFile))
+	} else if prepared.Body != "" {
+		args = append(args, "--data-raw "+shellQuote(prepared.Body))
+	}
+
+	return strings.Join(args, " \\\n  ") + "\n", nil
+}
+
+// CurlScript renders the named chain as a bash script that runs each step with
+// curl and uses jq to carry extracted values between steps.
+func (r *Runner) CurlScript(name string) (string, error) {
+	run, steps, err := r.enterChain(name)
+	if err != nil {
+		return "", err
+	}
+	run.tools = curlTools{}
+
+	vars, err := r.initialVariables()
+	if err != nil {
+		return "", err
+	}
+
+	var body strings.Builder
+	if err := run.writeCurlSteps(&body, steps, ""); err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "#!/usr/bin/env bash\n")
+	fmt.Fprintf(&b, "# Generated by afro from chain '%s'. Requires %s.\n", name, run.tools)
+	fmt.Fprintf(&b, "set -euo pipefail\n\n")
+	fmt.Fprintf(&b, "urlencode() { jq -rn --arg v \"$1\" '$v|@uri'; }\n")
+
+	if len(vars) > 0 {
+		b.WriteString("\n")
+		names := make([]string, 0, len(vars))
+		for k := range vars {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			fmt.Fprintf(&b, "%s=%s\n", shellName(k), shellQuote(formatValue(vars[k])))
+		}
+	}
+
+	// Soft failures are counted and fail the script at the end.
+	if !strings.Contains(body.String(), curlCountFailure) {
+		b.WriteString(body.String())
+		return b.String(), nil
+	}
+	b.WriteString("\nfailures=0\n")
+	b.WriteString(body.String())
+	b.WriteString("\nif [ \"$failures\" -gt 0 ]; then\n")
+	b.WriteString("  echo \"$failures checks failed\" >&2\n")
+	b.WriteString("  exit 1\n")
+	b.WriteString("fi\n")
+	return b.String(), nil
+}
+
+// curlTools records the commands besides curl and jq that a script uses, as
+// the parts using them are rendered. It may be nil when that is not needed.
+type curlTools map[string]bool
+
+func (t curlTools) use(tool string) {
+	if t != nil {
+		t[tool] = true
+	}
+}
+
+// String lists the commands a script needs, e.g. "curl, jq and uuidgen".
+func (t curlTools) String() string {
+	tools := []string{"curl", "jq"}
+	for _, tool := range []string{"perl", "xmllint", "uuidgen"} {
+		if t[tool] {
+			tools = append(tools, tool)
+		}
+	}
+	last := len(tools) - 1
+	return strings.Join(tools[:last], ", ") + " and " + tools[last]
+}
+
+// curlCountFailure counts a failed check that does not stop the script.
+const curlCountFailure = "failures=$((failures + 1))"
+
+func (r *Runner) writeCurlSteps(b *strings.Builder, steps []ChainStep, indent string) error {
+	for i, step := range steps {
+		if step.If != "" {
+			cond, err := parseCondition(step.If)
+			if err != nil {
+				return fmt.Errorf("invalid 'if' in step %d: %w", i+1, err)
+			}
+			test, err := cond.shell(r.tools)
+			if err != nil {
+				return err
+			}
+
+			then := step
+			then.If, then.Else = "", nil
+			if i > 0 || indent == "" {
+				b.WriteString("\n")
+			}
+			fmt.Fprintf(b, "%sif %s; then\n", indent, test)
+			if err := r.writeCurlSteps(b, []ChainStep{then}, indent+"  "); err != nil {
+				return err
+			}
+			if len(step.Else) > 0 {
+				fmt.Fprintf(b, "%selse\n", indent)
+				if err := r.writeCurlSteps(b, step.Else, indent+"  "); err != nil {
+					return err
+				}
+			}
+			fmt.Fprintf(b, "%sfi\n", indent)
+			continue
+		}
+		if len(step.Parallel) > 0 {
+			// Extracted values cannot leave a background job, so the group
+			// runs sequentially.
+			if i > 0 || indent == "" {
+				b.WriteString("\n")
+			}
+			fmt.Fprintf(b, "%s# Parallel group (run sequentially)\n", indent)
+			if err := r.writeCurlSteps(b, step.Parallel, indent); err != nil {
+				return err
+			}
+			continue
+		}
+		if step.Foreach != "" || step.Repeat > 0 {
+			if i > 0 || indent == "" {
+				b.WriteString("\n")
+			}
+			if err := r.writeCurlLoop(b, step, indent); err != nil {
+				return err
+			}
+			continue
+		}
+		if step.Until != nil {
+			if i > 0 || indent == "" {
+				b.WriteString("\n")
+			}
+			if err := r.writeCurlPoll(b, step, indent); err != nil {
+				return err
+			}
+			continue
+		}
+		if step.Chain != "" {
+			if i > 0 || indent == "" {
+				b.WriteString("\n")
+			}
+			if err := r.writeCurlChain(b, step, indent); err != nil {
+				return err
+			}
+			continue
+		}
+		if step.Request == "" && len(step.Steps) > 0 {
+			if err := r.writeCurlSteps(b, step.Steps, indent); err != nil {
+				return err
+			}
+			continue
+		}
+		if step.Request == "" {
+			return fmt.Errorf("step %d missing 'request' field", i+1)
+		}
+		saved, ok := r.Bundle.LookupRequest(step.Request)
+		if !ok {
+			return fmt.Errorf("request '%s' not found in config", step.Request)
+		}
+
+		// Placeholders are kept so they can become shell expansions.
+		prepared, err := r.prepare(saved, nil)
+		if err != nil {
+			return err
+		}
+
+		command := append([]string{"curl", "-sS", "-w '\\n%{http_code}'"}, curlMethodArgs(prepared)...)
+		if step.Timeout > 0 {
+			saved.Timeout = step.Timeout
+		}
+		if step.Retry != nil {
+			saved.Retry = step.Retry
+		}
+		retryArgs, err := r.curlRetryArgs(saved)
+		if err != nil {
+			return fmt.Errorf("step '%s': %w", step.Request, err)
+		}
+		command = append(command, retryArgs...)
+		dumpHeaders := extractsHeaders(step)
+		if dumpHeaders {
+			command = append(command, `-D "$headers"`)
+		}
+		command = append(command, shellTemplate(prepared.URL, step.Variables, true, r.tools))
+		args := []string{strings.Join(command, " ")}
+		for _, key := range sortedHeaderKeys(prepared.Header) {
+			for _, v := range prepared.Header[key] {
+				args = append(args, "-H "+shellTemplate(key+": "+v, step.Variables, false, r.tools))
+			}
+		}
+		if prepared.BodyFile != "" {
+			args = append(args, "--data-binary "+shellQuote("@"+prepared.BodyFile))
+		} else if prepared.Body != "" {
+			args = append(args, "--data-raw "+shellTemplate(prepared.Body, step.Variables, false, r.tools))
+		}
+
+		if i > 0 || indent == "" {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(b, "%s# Step: %s\n", indent, step.Request)
+		if dumpHeaders {
+			fmt.Fprintf(b, "%sheaders=$(mktemp)\n", indent)
+		}
+		// With an error branch, a failed curl runs it instead of exiting.
+		inner := indent
+		if len(step.OnError) > 0 {
+			inner = indent + "  "
+			fmt.Fprintf(b, "%sif ! response=$(%s); then\n", indent, strings.Join(args, " \\\n"+indent+"  "))
+			fmt.Fprintf(b, "%serror=%s\n", inner, shellQuote(fmt.Sprintf("step '%s' failed", step.Request)))
+			if err := r.writeCurlSteps(b, step.OnError, inner); err != nil {
+				return err
+			}
+			fmt.Fprintf(b, "%selse\n", indent)
+		} else {
+			fmt.Fprintf(b, "%sresponse=$(%s)\n", indent, strings.Join(args, " \\\n"+indent+"  "))
+		}
+		if err := r.writeCurlResponse(b, step, inner); err != nil {
+			return err
+		}
+		if len(step.OnError) > 0 {
+			fmt.Fprintf(b, "%sfi\n", indent)
+		}
+	}
+	return nil
+}
+
+// writeCurlResponse renders the handling of a step's response: extraction,
+// assertions and status branches.
+func (r *Runner) writeCurlResponse(b *strings.Builder, step ChainStep, indent string) error {
+	fmt.Fprintf(b, "%sstatus=${response##*$'\\n'}\n", indent)
+	fmt.Fprintf(b, "%sbody=${response%%$'\\n'*}\n", indent)
+	fmt.Fprintf(b, "%sprintf '%%s\\n' \"$body\"\n", indent)
+
+	varNames := make([]string, 0, len(step.Extract))
+	for k := range step.Extract {
+		varNames = append(varNames, k)
+	}
+	sort.Strings(varNames)
+	for _, varName := range varNames {
+		expr, err := curlExtraction(step.Extract[varName], r.tools)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(b, "%s%s=%s\n", indent, shellName(varName), expr)
+	}
+
+	if step.Schema != "" {
+		return fmt.Errorf("the response schema check of step '%s' cannot be exported to curl", step.Request)
+	}
+	if step.Snapshot != nil {
+		return fmt.Errorf("the response snapshot of step '%s' cannot be exported to curl", step.Request)
+	}
+	if err := writeCurlAssertions(b, step.Assert, step.Request, indent, step.ContinueOnError, r.tools); err != nil {
+		return err
+	}
+
+	if len(step.OnStatus) > 0 {
+		ranges, err := statusRanges(step.OnStatus)
+		if err != nil {
+			return fmt.Errorf("step '%s': %w", step.Request, err)
+		}
+
+		// Branches are tested most specific first, like at run time.
+		keyword := "if"
+		for _, rng := range ranges {
+			switch {
+			case strings.EqualFold(strings.TrimSpace(rng.key), "default"):
+				if keyword == "if" {
+					fmt.Fprintf(b, "%sif true; then\n", indent)
+				} else {
+					fmt.Fprintf(b, "%selse\n", indent)
+				}
+			case rng.min == rng.max:
+				fmt.Fprintf(b, "%s%s [ \"$status\" -eq %d ]; then\n", indent, keyword, rng.min)
+			default:
+				fmt.Fprintf(b, "%s%s [ \"$status\" -ge %d ] && [ \"$status\" -le %d ]; then\n", indent, keyword, rng.min, rng.max)
+			}
+			keyword = "elif"
+			if err := r.writeCurlSteps(b, step.OnStatus[rng.key], indent+"  "); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(b, "%sfi\n", indent)
+	}
+	return nil
+}
+
+// writeCurlAssertions renders assertions as tests that exit the script when
+// they fail. Soft assertions, and all of them when continuing on errors, are
+// counted instead.
+func writeCurlAssertions(b *strings.Builder, assertions []Assertion, stepName, indent string, continueOnError bool, tools curlTools) error {
+	for j, a := range assertions {
+		test, err := shellAssertion(a, tools)
+		if err != nil {
+			return err
+		}
+		onFailure := "exit 1"
+		if a.Soft || continueOnError {
+			onFailure = curlCountFailure
+		}
+		fmt.Fprintf(b, "%s%s || { echo %s >&2; %s; }\n", indent, test,
+			shellQuote(fmt.Sprintf("assertion %d failed in step '%s': %s %s %s", j+1, stepName, a.Left, a.Op, a.Right)), onFailure)
+	}
+	return nil
+}
+
+// writeCurlChain inlines the chain run by a step. Shell variables are global,
+// so its inputs are plain assignments and every variable it sets, not only its
+// outputs, is visible afterwards.
+func (r *Runner) writeCurlChain(b *strings.Builder, step ChainStep, indent string) error {
+	sub, steps, err := r.enterChain(step.Chain)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(b, "%s# Chain: %s\n", indent, step.Chain)
+	for _, k := range sortedKeys(step.Variables) {
+		fmt.Fprintf(b, "%s%s=%s\n", indent, shellName(k), shellTemplate(step.Variables[k], nil, false, r.tools))
+	}
+	if err := sub.writeCurlSteps(b, steps, indent); err != nil {
+		return err
+	}
+	return writeCurlAssertions(b, step.Assert, step.Chain, indent, step.ContinueOnError, r.tools)
+}
+
+// extractsHeaders reports whether the step extracts or asserts on headers or
+// cookies, which need curl to dump the response headers.
+func extractsHeaders(step ChainStep) bool {
+	paths := make([]string, 0, len(step.Extract)+len(step.Assert))
+	for _, path := range step.Extract {
+		paths = append(paths, path)
+	}
+	for _, a := range step.Assert {
+		if source, ok := responseSource(a.Left); ok {
+			paths = append(paths, source)
+		}
+	}
+	for _, path := range paths {
+		path = strings.TrimSpace(path)
+		if strings.HasPrefix(path, "header:") || strings.HasPrefix(path, "cookie:") {
+			return true
+		}
+	}
+	return false
+}
+
+// curlExtraction returns the shell expression reading an extraction source
+// from $status, $body or the dumped $headers. Regular expressions use perl and
+// XPaths xmllint; the content type is not known, so unprefixed paths are
+// XPaths only when they start with a slash.
+func curlExtraction(path string, tools curlTools) (string, error) {
+	source := strings.TrimSpace(path)
+	prefix, rest, _ := strings.Cut(source, ":")
+	rest = strings.TrimSpace(rest)
+
+	switch {
+	case source == "status":
+		return `"$status"`, nil
+	case source == "body":
+		return `"$body"`, nil
+	case source == "body_size":
+		return `$(printf '%s' "$body" | wc -c | tr -d ' ')`, nil
+	case source == "duration_ms":
+		return "", fmt.Errorf("'duration_ms' cannot be exported to curl")
+	case prefix == "header":
+		return fmt.Sprintf(`$({ grep -i %s "$headers" || true; } | tail -n 1 | cut -d ' ' -f 2- | tr -d '\r')`, shellQuote("^"+rest+":")), nil
+	case prefix == "cookie":
+		return fmt.Sprintf(`$({ grep -i %s "$headers" || true; } | tail -n 1 | sed -E 's/^[^=]*=//; s/;.*//' | tr -d '\r')`, shellQuote("^set-cookie: *"+rest+"=")), nil
+	case prefix == "regex":
+		re, err := regexp.Compile(rest)
+		if err != nil {
+			return "", err
+		}
+		tools.use("perl")
+		pattern := strings.ReplaceAll(rest, "/", `\/`)
+		if re.NumSubexp() > 1 {
+			// Several groups make a list, written as a JSON array like the
+			// values of other variables.
+			script := fmt.Sprintf(`print map { "$_\0" } @{^CAPTURE} if /%s/`, pattern)
+			return fmt.Sprintf(`$(printf '%%s' "$body" | perl -0777 -ne %s | jq -Rsc 'select(length > 0) | rtrimstr("\u0000") | split("\u0000")')`, shellQuote(script)), nil
+		}
+		script := fmt.Sprintf(`print @{^CAPTURE} ? $1 : $& if /%s/`, pattern)
+		return fmt.Sprintf(`$(printf '%%s' "$body" | perl -0777 -ne %s)`, shellQuote(script)), nil
+	case prefix == "xpath", prefix != "jsonpath" && strings.HasPrefix(source, "/"):
+		if prefix == "xpath" {
+			source = rest
+		}
+		tools.use("xmllint")
+		return fmt.Sprintf(`$(printf '%%s' "$body" | xmllint --xpath %s - 2>/dev/null)`, shellQuote("string("+source+")")), nil
+	case prefix == "jsonpath":
+		source = rest
+	}
+	return fmt.Sprintf(`$(printf '%%s' "$body" | jq -rc %s)`, shellQuote(jsonPathToJq(source))), nil
+}
+
+// writeCurlLoop renders a foreach or repeat step as a bash loop, collecting
+// values into JSON arrays with jq.
+func (r *Runner) writeCurlLoop(b *strings.Builder, step ChainStep, indent string) error {
+	body := step.Steps
+	if len(body) == 0 {
+		single := step
+		single.Foreach, single.Repeat, single.Collect = "", 0, nil
+		single.If, single.Else = "", nil
+		body = []ChainStep{single}
+	}
+
+	names := make([]string, 0, len(step.Collect))
+	for name := range step.Collect {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(b, "%s%s='[]'\n", indent, shellName(name))
+	}
+
+	if step.Foreach != "" {
+		fmt.Fprintf(b, "%sindex=0\n", indent)
+		fmt.Fprintf(b, "%swhile IFS= read -r item; do\n", indent)
+	} else {
+		fmt.Fprintf(b, "%sfor ((index = 0; index < %d; index++)); do\n", indent, step.Repeat)
+		fmt.Fprintf(b, "%s  item=$index\n", indent)
+	}
+
+	if err := r.writeCurlSteps(b, body, indent+"  "); err != nil {
+		return err
+	}
+	for _, name := range names {
+		fmt.Fprintf(b, "%s  %s=$(printf '%%s' \"$%s\" | jq -c --arg v \"${%s}\" '. + [$v]')\n",
+			indent, shellName(name), shellName(name), shellName(step.Collect[name]))
+	}
+
+	if step.Foreach != "" {
+		fmt.Fprintf(b, "%s  index=$((index + 1))\n", indent)
+		fmt.Fprintf(b, "%sdone < <(printf '%%s' \"${%s}\" | jq -rc '.[]')\n", indent, shellName(variableName(step.Foreach)))
+	} else {
+		fmt.Fprintf(b, "%sdone\n", indent)
+	}
+	return nil
+}
+
+// writeCurlPoll renders an until step as a bash loop that re-runs the request
+// until its assertions pass.
+func (r *Runner) writeCurlPoll(b *strings.Builder, step ChainStep, indent string) error {
+	conditions := make([]string, len(step.Assert))
+	for j, a := range step.Assert {
+		test, err := shellAssertion(a, r.tools)
+		if err != nil {
+			return err
+		}
+		conditions[j] = test
+	}
+
+	body := step
+	body.Until, body.Assert = nil, nil
+
+	interval := strconv.FormatFloat(step.Until.interval().Seconds(), 'f', -1, 64)
+	if step.Until.Timeout > 0 {
+		fmt.Fprintf(b, "%sdeadline=$((SECONDS + %d))\n", indent, int(step.Until.Timeout.Seconds()))
+	}
+	fmt.Fprintf(b, "%sfor ((attempt = 1; ; attempt++)); do\n", indent)
+	if err := r.writeCurlSteps(b, []ChainStep{body}, indent+"  "); err != nil {
+		return err
+	}
+	fmt.Fprintf(b, "%s  if %s; then break; fi\n", indent, strings.Join(conditions, " && "))
+	failure := shellQuote(fmt.Sprintf("condition not met in step '%s'", step.Request))
+	if maxAttempts := step.Until.maxAttempts(); maxAttempts > 0 {
+		fmt.Fprintf(b, "%s  [ \"$attempt\" -lt %d ] || { echo %s >&2; exit 1; }\n", indent, maxAttempts, failure)
+	}
+	if step.Until.Timeout > 0 {
+		fmt.Fprintf(b, "%s  [ \"$SECONDS\" -lt \"$deadline\" ] || { echo %s >&2; exit 1; }\n", indent, failure)
+	}
+	fmt.Fprintf(b, "%s  sleep %s\n", indent, interval)
+	fmt.Fprintf(b, "%sdone\n", indent)
+	return nil
+}
+
+// comparisonOps are the operators compare accepts.
+var comparisonOps = map[string]bool{"==": true, "!=": true, ">": true, ">=": true, "<": true, "<=": true}
+
+// shellAssertion renders an assertion as a shell test. A left side referring
+// to the response is read into $actual first.
+func shellAssertion(a Assertion, tools curlTools) (string, error) {
+	source, ok := responseSource(a.Left)
+	if !ok {
+		return shellTest(a.Left, a.Op, a.Right, tools)
+	}
+	expr, err := curlExtraction(source, tools)
+	if err != nil {
+		return "", err
+	}
+	// Missing headers and paths read as empty rather than unset.
+	switch strings.TrimSpace(a.Op) {
+	case "exists":
+		return fmt.Sprintf(`{ actual=%s; [ -n "${actual}" ]; }`, expr), nil
+	case "not_exists":
+		return fmt.Sprintf(`{ actual=%s; [ -z "${actual}" ]; }`, expr), nil
+	}
+	test, err := shellTest("{{actual}}", a.Op, a.Right, tools)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("{ actual=%s; %s; }", expr, test), nil
+}
+
+// shellTest renders a comparison as a shell test. Values in shell variables
+// are text, so arrays and objects extracted with jq are checked as JSON.
+func shellTest(left, op, right string, tools curlTools) (string, error) {
+	l := shellTemplate(left, nil, false, tools)
+	r := shellTemplate(right, nil, false, tools)
+	op = strings.TrimSpace(op)
+
+	if cmp, ok := strings.CutPrefix(op, "length "); ok {
+		cmp = strings.TrimSpace(cmp)
+		if !comparisonOps[cmp] {
+			return "", fmt.Errorf("unknown operator '%s'", op)
+		}
+		length := fmt.Sprintf(`"$(jq -rn --arg v %s '$v | try fromjson catch $v | length')"`, l)
+		return shellCompare(length, cmp, r), nil
+	}
+
+	switch op {
+	case "contains":
+		return fmt.Sprintf(`jq -en --arg v %s --arg x %s '$v | try fromjson catch $v | if type == "array" then any(.[]; tostring == $x) elif type == "object" then has($x) else tostring | contains($x) end' >/dev/null`, l, r), nil
+	case "not_contains":
+		test, _ := shellTest(left, "contains", right, tools)
+		return "! " + test, nil
+	case "matches":
+		tools.use("perl")
+		// perl's patterns are much closer to Go's than bash's are.
+		return fmt.Sprintf(`perl -e 'exit(($ARGV[0] =~ /$ARGV[1]/) ? 0 : 1)' %s %s`, l, r), nil
+	case "starts_with":
+		return fmt.Sprintf(`[[ %s == %s* ]]`, l, r), nil
+	case "in":
+		return fmt.Sprintf(`jq -en --arg v %s --arg list %s '$list | try fromjson catch ($list | split(",") | map(gsub("^ +| +$"; ""))) | any(.[]; tostring == $v)' >/dev/null`, l, r), nil
+	case "exists":
+		return `[ -n "${` + shellName(variableName(left)) + `+set}" ]`, nil
+	case "not_exists":
+		return `[ -z "${` + shellName(variableName(left)) + `+set}" ]`, nil
+	case "is_type":
+		return fmt.Sprintf(`[ "$(jq -rn --arg v %s '$v | try fromjson catch $v | type')" = %s ]`, l, r), nil
+	}
+
+	if !comparisonOps[op] {
+		return "", fmt.Errorf("unknown operator '%s'", op)
+	}
+	return shellCompare(l, op, r), nil
+}
+
+// shellCompare renders a comparison with jq. Two values that are numbers,
+// including decimals, are compared as numbers, so 3 == 3.0 like a JSON number
+// at run time, and any others as text. Shell variables do not keep JSON types,
+// so unlike at run time numeric text such as 00123 equals 123 too.
+func shellCompare(l, op, r string) string {
+	return fmt.Sprintf(`jq -en --arg l %s --arg r %s '[$l, $r | try tonumber catch null] as [$a, $b] | if $a != null and $b != null then $a %s $b else $l %s $r end' >/dev/null`, l, r, op, op)
+}
+
+// curlRetryArgs translates the request's timeout and retry policy to curl
+// options. curl picks the retried status codes itself, close to the default
+// ones, so policies listing their own or not retrying network errors cannot be
+// exported.
+func (r *Runner) curlRetryArgs(req Request) ([]string, error) {
+	var args []string
+	if timeout := r.timeout(req); timeout > 0 {
+		args = append(args, "--max-time "+strconv.FormatFloat(timeout.Seconds(), 'f', -1, 64))
+	}
+	policy := r.retryPolicy(req)
+	if attempts := policy.attempts(); attempts > 1 {
+		if len(policy.Statuses) > 0 {
+			return nil, fmt.Errorf("retrying statuses %v cannot be exported to curl", policy.Statuses)
+		}
+		if policy.NetworkErrors != nil && !*policy.NetworkErrors {
+			return nil, fmt.Errorf("retrying without network errors cannot be exported to curl")
+		}
+		args = append(args, "--retry "+strconv.Itoa(attempts-1), "--retry-connrefused")
+	}
+	return args, nil
+}
+
+func curlMethodArgs(prepared preparedRequest) []string {
+	switch {
+	case prepared.Method == http.MethodHead:
+		return []string{"--head"}
+	case prepared.Method == "" || (prepared.Method == http.MethodGet && prepared.Body == "" && prepared.BodyFile == ""):
+		return nil
+	default:
+		return []string{"-X " + prepared.Method}
+	}
+}
+
+func sortedHeaderKeys(header http.Header) []string {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// shellQuote quotes s as a single shell word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// shellTemplate renders tmpl as a double-quoted shell word, turning {{var}}
+// placeholders into shell expansions. Placeholders named in locals expand to
+// the local template instead, mirroring step-level variables.
+func shellTemplate(tmpl string, locals map[string]string, urlEncode bool, tools curlTools) string {
+	return `"` + shellTemplateBody(tmpl, locals, urlEncode, tools) + `"`
+}
+
+func shellTemplateBody(tmpl string, locals map[string]string, urlEncode bool, tools curlTools) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range placeholderPattern.FindAllStringSubmatchIndex(tmpl, -1) {
+		b.WriteString(shellEscape(tmpl[last:m[0]]))
+		last = m[1]
+
+		name := tmpl[m[2]:m[3]]
+		local, isLocal := lookupName(locals, name)
+		var expansion string
+		switch {
+		case name == "$timestamp":
+			expansion = "$(date +%s)"
+		case name == "$uuid":
+			tools.use("uuidgen")
+			expansion = "$(uuidgen)"
+		case isLocal && locals[local] != "":
+			// Locals are resolved against chain variables only.
+			expansion = shellTemplateBody(locals[local], nil, false, tools)
+		default:
+			expansion = "${" + shellName(name) + "}"
+		}
+		// A placeholder at the start of a URL stands for its base and is not escaped.
+		if urlEncode && m[0] > 0 && !strings.HasPrefix(name, "$") {
+			expansion = `$(urlencode "` + expansion + `")`
+		}
+		b.WriteString(expansion)
+	}
+	b.WriteString(shellEscape(tmpl[last:]))
+	return b.String()
+}
+
+// shellEscape escapes s for use inside double quotes.
+func shellEscape(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`")
+	return replacer.Replace(s)
+}
+
+// shellName turns a variable name into a valid shell identifier. Names are
+// lowercased, since placeholders match variables case-insensitively.
+func shellName(name string) string {
+	var b strings.Builder
+	for i, c := range name {
+		switch {
+		case c == '_' || (c >= 'a' && c <= 'z'):
+			b.WriteRune(c)
+		case c >= 'A' && c <= 'Z':
+			b.WriteRune(c + 'a' - 'A')
+		case c >= '0' && c <= '9':
+			if i == 0 {
+				b.WriteRune('_')
+			}
+			b.WriteRune(c)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+var wildcardIndex = regexp.MustCompile(`\[\*\]`)
+
+// jsonPathToJq converts the JSONPath expressions used by extract into jq filters.
+func jsonPathToJq(path string) string {
+	path = strings.TrimSpace(path)
+	path = strings.TrimPrefix(path, "$")
+
+	length := false
+	if strings.HasSuffix(path, ".length()") {
+		length = true
+		path = strings.TrimSuffix(path, ".length()")
+	}
+
+	filter := wildcardIndex.ReplaceAllString(path, "[]")
+	if filter == "" {
+		filter = "."
+	} else if !strings.HasPrefix(filter, ".") {
+		filter = "." + filter
+	}
+	if strings.Contains(filter, "[]") {
+		filter = "[" + filter + "]"
+	}
+	if length {
+		if filter == "." {
+			return "length"
+		}
+		filter += " | length"
+	}
+	return filter
+}
@@ -0,0 +1,133 @@
+package chain
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCurlCommand(t *testing.T) {
+	bundle := &Bundle{
+		BaseURL: "https://api.etin.dev",
+		Headers: map[string]string{"Accept": "application/json"},
+		Requests: map[string]Request{
+			"create_user": {
+				Method:  "POST",
+				URL:     "/users?team={{team}}",
+				Body:    `{"name": "O'Brien"}`,
+				Headers: []string{"Authorization: Bearer {{token}}"},
+			},
+		},
+	}
+
+	runner := New(bundle)
+	runner.Variables["team"] = "a b"
+	runner.Variables["token"] = "secret"
+
+	got, err := runner.CurlCommand("create_user")
+	if err != nil {
+		t.Fatalf("CurlCommand failed: %v", err)
+	}
+
+	expected := `curl -X POST 'https://api.etin.dev/users?team=a+b' \
+  -H 'Accept: application/json' \
+  -H 'Authorization: Bearer secret' \
+  --data-raw '{"name": "O'\''Brien"}'
+`
+	if got != expected {
+		t.Errorf("unexpected command:\n%s\nexpected:\n%s", got, expected)
+	}
+}
+
+func TestCurlScript(t *testing.T) {
+	bundle := &Bundle{
+		BaseURL: "https://api.etin.dev",
+		Requests: map[string]Request{
+			"login": {Method: "POST", URL: "/login"},
+			"me": {
+				Method:  "GET",
+				URL:     "/users/{{ID}}",
+				Headers: []string{"Authorization: Bearer {{token}}"},
+			},
+		},
+		Chains: map[string][]ChainStep{
+			"flow": {
+				{Request: "login", Extract: map[string]string{"token_a": "$.token", "user_id": "$.user.id"}},
+				{
+					Request:   "me",
+					Variables: map[string]string{"token": "{{Token_A}}", "id": "{{user_id}}"},
+					Assert:    []Assertion{{Left: "{{user_id}}", Op: ">", Right: "0"}},
+				},
+			},
+		},
+	}
+
+	got, err := New(bundle).CurlScript("flow")
+	if err != nil {
+		t.Fatalf("CurlScript failed: %v", err)
+	}
+
+	for _, line := range []string{
+		`token_a=$(printf '%s' "$body" | jq -rc '.token')`,
+		`user_id=$(printf '%s' "$body" | jq -rc '.user.id')`,
+		`response=$(curl -sS -w '\n%{http_code}' "https://api.etin.dev/users/$(urlencode "${user_id}")" \`,
+		`  -H "Authorization: Bearer ${token_a}")`,
+		`jq -en --arg l "${user_id}" --arg r "0" '[$l, $r | try tonumber catch null] as [$a, $b] | if $a != null and $b != null then $a > $b else $l > $r end' >/dev/null || {`,
+	} {
+		if !strings.Contains(got, line) {
+			t.Errorf("expected script to contain %q, got:\n%s", line, got)
+		}
+	}
+}
+
+func TestCurlScriptTools(t *testing.T) {
+	bundle := &Bundle{
+		BaseURL: "https://api.etin.dev",
+		Requests: map[string]Request{
+			"get":    {Method: "GET", URL: "/items"},
+			"create": {Method: "POST", URL: "/items", Body: `{"id":"{{$uuid}}"}`},
+			"note":   {Method: "POST", URL: "/notes", Headers: []string{"X-Note: perl and xmllint"}, Body: `run uuidgen or $(uuidgen)`},
+		},
+		Chains: map[string][]ChainStep{
+			"plain": {{Request: "get"}},
+			// Text that only mentions the commands does not need them.
+			"mentions": {{Request: "note"}},
+			"matches":  {{Request: "get", Assert: []Assertion{{Left: "{{id}}", Op: "matches", Right: "^\\d+$"}}}},
+			"all": {
+				{Request: "get", Extract: map[string]string{"id": "xpath://item/@id", "code": "regex:code=(\\d+)"}},
+				{Request: "create"},
+			},
+		},
+	}
+
+	for chain, want := range map[string]string{
+		"plain":    "# Generated by afro from chain 'plain'. Requires curl and jq.\n",
+		"mentions": "# Generated by afro from chain 'mentions'. Requires curl and jq.\n",
+		"matches":  "# Generated by afro from chain 'matches'. Requires curl, jq and perl.\n",
+		"all":      "# Generated by afro from chain 'all'. Requires curl, jq, perl, xmllint and uuidgen.\n",
+	} {
+		got, err := New(bundle).CurlScript(chain)
+		if err != nil {
+			t.Fatalf("CurlScript(%q) failed: %v", chain, err)
+		}
+		if !strings.Contains(got, want) {
+			t.Errorf("expected script to contain %q, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestJSONPathToJq(t *testing.T) {
+	tests := map[string]string{
+		"$":                ".",
+		"$.token":          ".token",
+		"$.user.email":     ".user.email",
+		"$.items[0].id":    ".items[0].id",
+		"$.items[*].id":    "[.items[].id]",
+		"$.length()":       "length",
+		"$.items.length()": ".items | length",
+	}
+	for path, expected := range tests {
+		if got := jsonPathToJq(path); got != expected {
+			t.Errorf("jsonPathToJq(%q) = %q, expected %q", path, got, expected)
+		}
+	}
+}
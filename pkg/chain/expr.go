@@ -0,0 +1,340 @@
+package chain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// condition is a parsed `if` expression. Operands are templates that are
+// substituted when the condition is evaluated.
+type condition interface {
+	eval(vars map[string]interface{}) (bool, error)
+	// shell renders the condition as a bash test.
+	shell(tools curlTools) (string, error)
+}
+
+type andCondition struct{ left, right condition }
+type orCondition struct{ left, right condition }
+type notCondition struct{ operand condition }
+type existsCondition struct{ name string }
+
+// compareCondition compares two operands with one of the assertion operators.
+type compareCondition struct{ left, op, right string }
+
+// truthyCondition holds when its operand is not empty, "false", "0" or "null".
+type truthyCondition struct{ operand string }
+
+func (c andCondition) eval(vars map[string]interface{}) (bool, error) {
+	ok, err := c.left.eval(vars)
+	if err != nil || !ok {
+		return false, err
+	}
+	return c.right.eval(vars)
+}
+
+func (c orCondition) eval(vars map[string]interface{}) (bool, error) {
+	ok, err := c.left.eval(vars)
+	if err != nil || ok {
+		return ok, err
+	}
+	return c.right.eval(vars)
+}
+
+func (c notCondition) eval(vars map[string]interface{}) (bool, error) {
+	ok, err := c.operand.eval(vars)
+	return !ok, err
+}
+
+func (c existsCondition) eval(vars map[string]interface{}) (bool, error) {
+	_, ok := lookupVariable(vars, c.name)
+	return ok, nil
+}
+
+func (c compareCondition) eval(vars map[string]interface{}) (bool, error) {
+	return evaluate(resolveOperand(c.left, vars), c.op, resolveOperand(c.right, vars))
+}
+
+func (c truthyCondition) eval(vars map[string]interface{}) (bool, error) {
+	switch substitute(c.operand, vars) {
+	case "", "false", "0", "null":
+		return false, nil
+	default:
+		return true, nil
+	}
+}
+
+func (c andCondition) shell(tools curlTools) (string, error) {
+	return shellJoin(c.left, "&&", c.right, tools)
+}
+func (c orCondition) shell(tools curlTools) (string, error) {
+	return shellJoin(c.left, "||", c.right, tools)
+}
+
+func (c notCondition) shell(tools curlTools) (string, error) {
+	s, err := c.operand.shell(tools)
+	return "! " + s, err
+}
+
+func (c existsCondition) shell(curlTools) (string, error) {
+	return `[ -n "${` + shellName(c.name) + `+set}" ]`, nil
+}
+
+func (c compareCondition) shell(tools curlTools) (string, error) {
+	return shellTest(c.left, c.op, c.right, tools)
+}
+
+func (c truthyCondition) shell(tools curlTools) (string, error) {
+	v := shellTemplate(c.operand, nil, false, tools)
+	return fmt.Sprintf("{ [ -n %s ] && [ %s != false ] && [ %s != 0 ] && [ %s != null ]; }", v, v, v, v), nil
+}
+
+func shellJoin(left condition, op string, right condition, tools curlTools) (string, error) {
+	l, err := left.shell(tools)
+	if err != nil {
+		return "", err
+	}
+	r, err := right.shell(tools)
+	if err != nil {
+		return "", err
+	}
+	return "{ " + l + " " + op + " " + r + "; }", nil
+}
+
+// evaluateCondition parses and evaluates an `if` expression.
+func evaluateCondition(expr string, vars map[string]interface{}) (bool, error) {
+	cond, err := parseCondition(expr)
+	if err != nil {
+		return false, err
+	}
+	return cond.eval(vars)
+}
+
+// parseCondition parses an expression such as
+//
+//	exists token && ({{role}} == "admin" || !{{readonly}})
+//
+// Bare words and quoted strings are literals, and {{var}} placeholders are
+// substituted. Comparisons use the assertion operators, such as
+// `{{tags}} contains admin`, `{{id}} exists` or `{{items}} length > 0`.
+func parseCondition(expr string) (condition, error) {
+	tokens, err := tokenizeCondition(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &conditionParser{tokens: tokens}
+	cond, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected '%s' in expression '%s'", p.tokens[p.pos].text, expr)
+	}
+	return cond, nil
+}
+
+type conditionToken struct {
+	text string
+	// operand is true for literals and placeholders, as opposed to operators.
+	operand bool
+	quoted  bool
+}
+
+// wordOperators are the assertion operators written as words. Those without
+// a right operand are listed as false.
+var wordOperators = map[string]bool{
+	"contains":     true,
+	"not_contains": true,
+	"matches":      true,
+	"starts_with":  true,
+	"in":           true,
+	"is_type":      true,
+	"exists":       false,
+	"not_exists":   false,
+}
+
+var conditionOperators = []string{"&&", "||", "==", "!=", ">=", "<=", ">", "<", "!", "(", ")"}
+
+func tokenizeCondition(expr string) ([]conditionToken, error) {
+	var tokens []conditionToken
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		if c == ' ' || c == '\t' || c == '\n' {
+			i++
+			continue
+		}
+
+		if c == '"' || c == '\'' {
+			end := strings.IndexByte(expr[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string in expression '%s'", expr)
+			}
+			tokens = append(tokens, conditionToken{text: expr[i+1 : i+1+end], operand: true, quoted: true})
+			i += end + 2
+			continue
+		}
+
+		matched := false
+		for _, op := range conditionOperators {
+			if strings.HasPrefix(expr[i:], op) {
+				tokens = append(tokens, conditionToken{text: op})
+				i += len(op)
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+
+		// A bare word runs until whitespace or an operator, keeping any
+		// {{placeholders}} whole.
+		start := i
+		for i < len(expr) && !strings.ContainsRune(" \t\n\"'()!=<>&|", rune(expr[i])) {
+			if strings.HasPrefix(expr[i:], "{{") {
+				if end := strings.Index(expr[i:], "}}"); end >= 0 {
+					i += end + 2
+					continue
+				}
+			}
+			i++
+		}
+		tokens = append(tokens, conditionToken{text: expr[start:i], operand: true})
+	}
+	return tokens, nil
+}
+
+type conditionParser struct {
+	tokens []conditionToken
+	pos    int
+}
+
+func (p *conditionParser) peek() (conditionToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return conditionToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+// accept consumes the next token if it is the given operator.
+func (p *conditionParser) accept(op string) bool {
+	if tok, ok := p.peek(); ok && !tok.operand && tok.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *conditionParser) parseOr() (condition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orCondition{left, right}
+	}
+	return left, nil
+}
+
+func (p *conditionParser) parseAnd() (condition, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andCondition{left, right}
+	}
+	return left, nil
+}
+
+func (p *conditionParser) parseUnary() (condition, error) {
+	if p.accept("!") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notCondition{operand}, nil
+	}
+	if p.accept("(") {
+		cond, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, fmt.Errorf("missing ')'")
+		}
+		return cond, nil
+	}
+
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	if left == "exists" {
+		if tok, ok := p.peek(); ok && tok.operand {
+			p.pos++
+			return existsCondition{name: variableName(tok.text)}, nil
+		}
+	}
+
+	op, hasRight, ok := p.operator()
+	if !ok {
+		return truthyCondition{operand: left}, nil
+	}
+	var right string
+	if hasRight {
+		if right, err = p.operand(); err != nil {
+			return nil, err
+		}
+	}
+	return compareCondition{left: left, op: op, right: right}, nil
+}
+
+// operator consumes the assertion operator following an operand, if there is
+// one, and reports whether it takes a right operand.
+func (p *conditionParser) operator() (string, bool, bool) {
+	tok, ok := p.peek()
+	if !ok {
+		return "", false, false
+	}
+	if !tok.operand {
+		if comparisonOps[tok.text] {
+			p.pos++
+			return tok.text, true, true
+		}
+		return "", false, false
+	}
+	if tok.quoted {
+		return "", false, false
+	}
+	if tok.text == "length" && p.pos+1 < len(p.tokens) {
+		if comparisonOps[p.tokens[p.pos+1].text] && !p.tokens[p.pos+1].operand {
+			p.pos += 2
+			return "length " + p.tokens[p.pos-1].text, true, true
+		}
+	}
+	if hasRight, isWord := wordOperators[tok.text]; isWord {
+		p.pos++
+		return tok.text, hasRight, true
+	}
+	return "", false, false
+}
+
+func (p *conditionParser) operand() (string, error) {
+	tok, ok := p.peek()
+	if !ok {
+		return "", fmt.Errorf("unexpected end of expression")
+	}
+	if !tok.operand {
+		return "", fmt.Errorf("unexpected '%s'", tok.text)
+	}
+	p.pos++
+	return tok.text, nil
+}
@@ -0,0 +1,149 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEvaluateCondition(t *testing.T) {
+	vars := map[string]interface{}{
+		"role":     "admin",
+		"count":    float64(12),
+		"readonly": false,
+		"name":     "Ada Lovelace",
+		"tags":     []interface{}{"admin", "beta"},
+		"zip":      "00123",
+	}
+
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{`{{role}} == admin`, true},
+		{`{{role}} == "admin"`, true},
+		{`{{role}} != 'admin'`, false},
+		{`{{count}} > 9`, true},
+		{`{{count}} <= 9`, false},
+		{`exists role`, true},
+		{`exists {{token}}`, false},
+		{`!exists token`, true},
+		{`{{readonly}}`, false},
+		{`!{{readonly}}`, true},
+		{`{{name}} == "Ada Lovelace"`, true},
+		{`exists token || {{role}} == admin`, true},
+		{`exists token || {{role}} == admin && {{count}} < 10`, false},
+		{`(exists token || {{role}} == admin) && !({{count}} < 10)`, true},
+		{`{{tags}} contains admin`, true},
+		{`{{tags}} not_contains admin`, false},
+		{`{{name}} matches "^Ada \w+$"`, true},
+		{`{{name}} starts_with Ada`, true},
+		{`{{role}} in "admin, owner"`, true},
+		{`{{role}} in {{tags}}`, true},
+		{`{{role}} exists && {{token}} not_exists`, true},
+		{`{{tags}} is_type array`, true},
+		{`{{tags}} length == 2 && {{name}} length > 20`, false},
+		{`{{count}} == 12.0`, true},
+		{`{{zip}} == 123`, false},
+		{`"contains" == contains`, true},
+	}
+
+	for _, tt := range tests {
+		got, err := evaluateCondition(tt.expr, vars)
+		if err != nil {
+			t.Errorf("evaluateCondition(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("evaluateCondition(%q) = %v, expected %v", tt.expr, got, tt.expected)
+		}
+	}
+}
+
+func TestEvaluateConditionErrors(t *testing.T) {
+	for _, expr := range []string{`{{a}} ==`, `({{a}} == b`, `{{a}} == b )`, `"open`, `&& b`} {
+		if _, err := evaluateCondition(expr, nil); err == nil {
+			t.Errorf("expected %q to fail to parse", expr)
+		}
+	}
+}
+
+func TestIfElseSteps(t *testing.T) {
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		fmt.Fprint(w, `{"plan":"free"}`)
+	}))
+	defer ts.Close()
+
+	bundle := &Bundle{
+		BaseURL: ts.URL,
+		Requests: map[string]Request{
+			"account": {Method: "GET", URL: "/account"},
+			"upgrade": {Method: "POST", URL: "/upgrade"},
+			"invoice": {Method: "GET", URL: "/invoice"},
+		},
+		Chains: map[string][]ChainStep{
+			"flow": {
+				{Request: "account", Extract: map[string]string{"plan": "$.plan"}},
+				{
+					Request: "invoice",
+					If:      `{{plan}} == "pro"`,
+					Else:    []ChainStep{{Request: "upgrade"}},
+				},
+				{Request: "invoice", If: "exists coupon"},
+			},
+		},
+	}
+
+	var log bytes.Buffer
+	runner := New(bundle)
+	runner.Out = &bytes.Buffer{}
+	runner.Log = &log
+
+	if err := runner.Run(context.Background(), "flow"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if strings.Join(paths, ",") != "/account,/upgrade" {
+		t.Errorf("unexpected requests %v", paths)
+	}
+	if !strings.Contains(log.String(), "Skipping step 3, condition 'exists coupon' is false") {
+		t.Errorf("expected skipped step to be logged, got %q", log.String())
+	}
+}
+
+func TestCurlScriptIf(t *testing.T) {
+	bundle := &Bundle{
+		BaseURL: "https://api.etin.dev",
+		Requests: map[string]Request{
+			"upgrade": {Method: "POST", URL: "/upgrade"},
+			"login":   {Method: "POST", URL: "/login"},
+		},
+		Chains: map[string][]ChainStep{
+			"flow": {{
+				Request: "upgrade",
+				If:      `exists token && {{plan}} != pro`,
+				Else:    []ChainStep{{Request: "login"}},
+			}},
+		},
+	}
+
+	got, err := New(bundle).CurlScript("flow")
+	if err != nil {
+		t.Fatalf("CurlScript failed: %v", err)
+	}
+
+	for _, want := range []string{
+		"if { [ -n \"${token+set}\" ] && jq -en --arg l \"${plan}\" --arg r \"pro\" '[$l, $r | try tonumber catch null] as [$a, $b] | if $a != null and $b != null then $a != $b else $l != $r end' >/dev/null; }; then\n  # Step: upgrade\n",
+		"else\n  # Step: login\n",
+		"fi\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected script to contain %q, got:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package chain
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/oliveagle/jsonpath"
+)
+
+// extract sets the step's extracted variables from the response. Sources are
+//
+//	status          the status code
+//	body            the raw body text
+//	duration_ms     how long the response took, in milliseconds
+//	body_size       the size of the body in bytes
+//	header:<name>   a response header
+//	cookie:<name>   a cookie set by the response
+//	regex:<re>      the first match of a regular expression in the body, or
+//	                its capture group(s)
+//	xpath:<path>    an XPath into an XML or HTML body
+//	jsonpath:<path> a JSONPath into a JSON body
+//
+// Anything else is an XPath when the response is XML or HTML, and a JSONPath
+// otherwise. Values that cannot be extracted are reported as warnings, or in
+// strict mode returned as an *extractionError. A status handled by an
+// on_status branch is expected to differ from the usual response, e.g. an
+// error body, so misses then are warnings even in strict mode.
+func (r *Runner) extract(step ChainStep, resp *response, variables map[string]interface{}) error {
+	strict := r.Strict
+	if _, handled, _ := matchStatus(step.OnStatus, resp.status()); handled {
+		strict = false
+	}
+	doc := &responseDocument{resp: resp}
+	var failed []string
+	for _, varName := range sortedKeys(step.Extract) {
+		path := step.Extract[varName]
+		value, err := doc.extract(path)
+		if err != nil {
+			if strict {
+				failed = append(failed, fmt.Sprintf("'%s' using '%s': %v", varName, path, err))
+			} else {
+				fmt.Fprintf(r.log(), "Warning: failed to extract '%s' using '%s' in step '%s': %v\n", varName, path, step.Request, err)
+			}
+			continue
+		}
+		variables[varName] = value
+	}
+	if len(failed) > 0 {
+		return &extractionError{failed: failed}
+	}
+	return nil
+}
+
+// extractionError lists the variables a step failed to extract.
+type extractionError struct {
+	failed []string
+}
+
+func (e *extractionError) Error() string {
+	return "failed to extract " + strings.Join(e.failed, "; ")
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// responseDocument parses a response body at most once per format.
+type responseDocument struct {
+	resp *response
+
+	jsonParsed bool
+	json       interface{}
+	jsonErr    error
+
+	xmlParsed bool
+	xml       *xmlNode
+	xmlErr    error
+}
+
+func (d *responseDocument) extract(path string) (interface{}, error) {
+	source := strings.TrimSpace(path)
+	prefix, rest, _ := strings.Cut(source, ":")
+	rest = strings.TrimSpace(rest)
+
+	switch {
+	case source == "status":
+		return d.resp.StatusCode, nil
+	case source == "body":
+		return string(d.resp.Body), nil
+	case source == "duration_ms":
+		return milliseconds(d.resp.Duration), nil
+	case source == "body_size":
+		return len(d.resp.Body), nil
+	case prefix == "header":
+		values := d.resp.Header.Values(rest)
+		if len(values) == 0 {
+			return nil, fmt.Errorf("header '%s' not found", rest)
+		}
+		return strings.Join(values, ", "), nil
+	case prefix == "cookie":
+		value, ok := d.resp.cookie(rest)
+		if !ok {
+			return nil, fmt.Errorf("cookie '%s' not found", rest)
+		}
+		return value, nil
+	case prefix == "regex":
+		return regexLookup(d.resp.Body, rest)
+	case prefix == "xpath":
+		return d.xpath(rest)
+	case prefix == "jsonpath":
+		return d.jsonPath(rest)
+	case isMarkupContentType(d.resp.Header.Get("Content-Type")):
+		return d.xpath(source)
+	default:
+		return d.jsonPath(source)
+	}
+}
+
+func (d *responseDocument) jsonPath(path string) (interface{}, error) {
+	if !d.jsonParsed {
+		d.jsonParsed = true
+		d.jsonErr = json.Unmarshal(d.resp.Body, &d.json)
+	}
+	if d.jsonErr != nil {
+		return nil, fmt.Errorf("failed to parse response as JSON: %w", d.jsonErr)
+	}
+	return jsonpath.JsonPathLookup(d.json, path)
+}
+
+func (d *responseDocument) xpath(path string) (interface{}, error) {
+	if !d.xmlParsed {
+		d.xmlParsed = true
+		d.xml, d.xmlErr = parseXML(d.resp.Body)
+	}
+	if d.xmlErr != nil {
+		return nil, fmt.Errorf("failed to parse response as XML: %w", d.xmlErr)
+	}
+	return xpathLookup(d.xml, path)
+}
+
+// regexLookup returns the first match of pattern in body. With one capture
+// group its value is returned instead, and with several a list of them.
+func regexLookup(body []byte, pattern string) (interface{}, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	match := re.FindSubmatch(body)
+	if match == nil {
+		return nil, fmt.Errorf("no match for '%s'", pattern)
+	}
+	switch len(match) {
+	case 1:
+		return string(match[0]), nil
+	case 2:
+		return string(match[1]), nil
+	default:
+		groups := make([]interface{}, len(match)-1)
+		for i, g := range match[1:] {
+			groups[i] = string(g)
+		}
+		return groups, nil
+	}
+}
+
+func isMarkupContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "text/html" || mediaType == "text/xml" || mediaType == "application/xml" ||
+		strings.HasSuffix(mediaType, "+xml")
+}
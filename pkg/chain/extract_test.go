@@ -0,0 +1,175 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractSources(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/inventories/42")
+		http.SetCookie(w, &http.Cookie{Name: "session_id", Value: "abc123", Path: "/"})
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created"))
+	}))
+	defer ts.Close()
+
+	bundle := &Bundle{
+		BaseURL:  ts.URL,
+		Requests: map[string]Request{"create": {Method: "POST", URL: "/inventories"}},
+		Chains: map[string][]ChainStep{
+			"flow": {{
+				Request: "create",
+				Extract: map[string]string{
+					"location": "header:Location",
+					"session":  "cookie:session_id",
+					"code":     "status",
+					"text":     "body",
+					"missing":  "header:X-Missing",
+				},
+			}},
+		},
+	}
+
+	var log bytes.Buffer
+	runner := New(bundle)
+	runner.Out = &bytes.Buffer{}
+	runner.Log = &log
+
+	variables := map[string]interface{}{}
+	if err := runner.executeSteps(context.Background(), bundle.Chains["flow"], variables); err != nil {
+		t.Fatalf("executeSteps failed: %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"location": "/inventories/42",
+		"session":  "abc123",
+		"code":     http.StatusCreated,
+		"text":     "created",
+	}
+	for k, v := range expected {
+		if variables[k] != v {
+			t.Errorf("expected %s = %v, got %v", k, v, variables[k])
+		}
+	}
+	if _, ok := variables["missing"]; ok {
+		t.Error("expected missing header not to be extracted")
+	}
+	if !strings.Contains(log.String(), "header 'X-Missing' not found") {
+		t.Errorf("expected a warning for the missing header, got %q", log.String())
+	}
+	if strings.Contains(log.String(), "failed to parse response") {
+		t.Errorf("expected the non-JSON body not to be parsed, got %q", log.String())
+	}
+}
+
+func TestExtractRegexAndXPath(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/legacy":
+			w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+			fmt.Fprint(w, `<order><id>77</id><status code="S1">shipped</status></order>`)
+		case "/text":
+			fmt.Fprint(w, "job=42 state=done")
+		}
+	}))
+	defer ts.Close()
+
+	bundle := &Bundle{
+		BaseURL: ts.URL,
+		Requests: map[string]Request{
+			"legacy": {Method: "GET", URL: "/legacy"},
+			"text":   {Method: "GET", URL: "/text"},
+		},
+		Chains: map[string][]ChainStep{
+			"flow": {
+				{
+					Request: "legacy",
+					Extract: map[string]string{
+						"order_id": "/order/id",
+						"code":     "xpath://status/@code",
+						"state":    `regex:<status[^>]*>(\w+)<`,
+					},
+				},
+				{
+					Request: "text",
+					Extract: map[string]string{
+						"job":   `regex:job=\d+`,
+						"pair":  `regex:job=(\d+) state=(\w+)`,
+						"other": "$.id",
+					},
+				},
+			},
+		},
+	}
+
+	var log bytes.Buffer
+	runner := New(bundle)
+	runner.Out = &bytes.Buffer{}
+	runner.Log = &log
+
+	variables := map[string]interface{}{}
+	if err := runner.executeSteps(context.Background(), bundle.Chains["flow"], variables); err != nil {
+		t.Fatalf("executeSteps failed: %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"order_id": "77",
+		"code":     "S1",
+		"state":    "shipped",
+		"job":      "job=42",
+		"pair":     []interface{}{"42", "done"},
+	}
+	for k, v := range expected {
+		if !reflect.DeepEqual(variables[k], v) {
+			t.Errorf("expected %s = %v, got %v", k, v, variables[k])
+		}
+	}
+	if !strings.Contains(log.String(), "failed to extract 'other' using '$.id' in step 'text': failed to parse response as JSON") {
+		t.Errorf("expected a warning for the JSON path, got %q", log.String())
+	}
+}
+
+func TestCurlScriptExtractSources(t *testing.T) {
+	bundle := &Bundle{
+		BaseURL:  "https://api.etin.dev",
+		Requests: map[string]Request{"login": {Method: "POST", URL: "/login"}},
+		Chains: map[string][]ChainStep{
+			"flow": {{
+				Request: "login",
+				Extract: map[string]string{
+					"session":  "cookie:session_id",
+					"location": "header:Location",
+					"code":     "status",
+					"job":      `regex:job=(\d+)`,
+					"pair":     `regex:job=(\d+) state=(\w+)`,
+				},
+			}},
+		},
+	}
+
+	got, err := New(bundle).CurlScript("flow")
+	if err != nil {
+		t.Fatalf("CurlScript failed: %v", err)
+	}
+
+	for _, want := range []string{
+		"headers=$(mktemp)\nresponse=$(curl -sS -w '\\n%{http_code}' -X POST -D \"$headers\" \"https://api.etin.dev/login\")\n",
+		`code="$status"`,
+		`location=$({ grep -i '^Location:' "$headers" || true; } | tail -n 1 | cut -d ' ' -f 2- | tr -d '\r')`,
+		`session=$({ grep -i '^set-cookie: *session_id=' "$headers" || true; } | tail -n 1 | sed -E 's/^[^=]*=//; s/;.*//' | tr -d '\r')`,
+		`job=$(printf '%s' "$body" | perl -0777 -ne 'print @{^CAPTURE} ? $1 : $& if /job=(\d+)/')`,
+		// Several groups are read as a JSON array.
+		`pair=$(printf '%s' "$body" | perl -0777 -ne 'print map { "$_\0" } @{^CAPTURE} if /job=(\d+) state=(\w+)/' | jq -Rsc 'select(length > 0) | rtrimstr("\u0000") | split("\u0000")')`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected script to contain %q, got:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package chain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// softAssertionError lists the soft assertions of a step that failed while
+// the others passed.
+type softAssertionError struct {
+	failures []string
+}
+
+func (e *softAssertionError) Error() string {
+	return strings.Join(e.failures, "; ")
+}
+
+// failureList collects the failures a run carries on after, so it can fail
+// once every step has run.
+type failureList struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+// deferFailure records err to fail the run when it finishes instead of
+// stopping it now. Outside of Run there is nothing to report it at the end,
+// so err is returned as is.
+func (r *Runner) deferFailure(err error) error {
+	if r.failures == nil {
+		return err
+	}
+	fmt.Fprintf(r.log(), "Continuing after failure: %v\n", err)
+	r.failures.mu.Lock()
+	defer r.failures.mu.Unlock()
+	r.failures.errs = append(r.failures.errs, err)
+	return nil
+}
+
+// err returns the error a run fails with: the failures carried on after,
+// followed by runErr, the one that stopped it, if any.
+func (f *failureList) err(runErr error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	errs := f.errs
+	if runErr != nil {
+		errs = append(errs, runErr)
+	}
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		return fmt.Errorf("%d failures:\n%w", len(errs), errors.Join(errs...))
+	}
+}
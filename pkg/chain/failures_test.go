@@ -0,0 +1,119 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestContinueOnFailure(t *testing.T) {
+	var calls []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.URL.Path)
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write([]byte(`{"name":"ada","age":36}`))
+	}))
+	defer ts.Close()
+
+	bundle := &Bundle{
+		BaseURL: ts.URL,
+		Requests: map[string]Request{
+			"user":   {Method: "GET", URL: "/user"},
+			"broken": {Method: "GET", URL: "/broken"},
+			"last":   {Method: "GET", URL: "/last"},
+		},
+		Chains: map[string][]ChainStep{
+			"flow": {
+				{Request: "user", Assert: []Assertion{
+					{Left: "jsonpath:$.name", Op: "==", Right: "grace", Soft: true},
+					{Left: "jsonpath:$.age", Op: ">", Right: "40", Soft: true},
+					{Left: "status", Op: "==", Right: "200"},
+				}},
+				{Request: "broken", ContinueOnError: true, Assert: []Assertion{{Left: "status", Op: "==", Right: "200"}}},
+				{Request: "last"},
+			},
+		},
+	}
+	runner := New(bundle)
+	runner.Out = &bytes.Buffer{}
+	runner.Log = nil
+	runner.Report = &Report{}
+
+	err := runner.Run(context.Background(), "flow")
+	if err == nil {
+		t.Fatal("expected the run to fail")
+	}
+	if strings.Join(calls, " ") != "/user /broken /last" {
+		t.Errorf("expected every step to run, got %v", calls)
+	}
+	for _, s := range []string{
+		"2 failures:",
+		"assertion failed in step 'user': assertion 1 failed: 'ada' == 'grace'; assertion 2 failed: '36' > '40'",
+		"assertion failed in step 'broken': assertion 1 failed: '500' == '200'",
+	} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("expected the error to contain %q, got: %v", s, err)
+		}
+	}
+
+	steps := runner.Report.Steps
+	if len(steps) != 3 || !steps[0].Failed() || !steps[1].Failed() || steps[2].Failed() {
+		t.Fatalf("unexpected report steps %+v", steps)
+	}
+	if a := steps[0].Assertions; len(a) != 3 || !a[0].Soft || a[0].Passed || !a[2].Passed {
+		t.Errorf("expected every assertion to be checked, got %+v", a)
+	}
+
+	// A failing hard assertion still stops the chain.
+	calls = nil
+	bundle.Chains["flow"][1].ContinueOnError = false
+	err = runner.Run(context.Background(), "flow")
+	if err == nil || !strings.Contains(err.Error(), "2 failures:") {
+		t.Errorf("expected the soft failures and the stopping one, got %v", err)
+	}
+	if strings.Join(calls, " ") != "/user /broken" {
+		t.Errorf("expected the chain to stop at the failing step, got %v", calls)
+	}
+}
+
+func TestCurlScriptSoftAssertions(t *testing.T) {
+	bundle := &Bundle{
+		BaseURL:  "https://api.etin.dev",
+		Requests: map[string]Request{"user": {Method: "GET", URL: "/user"}},
+		Chains: map[string][]ChainStep{
+			"flow": {{Request: "user", Assert: []Assertion{
+				{Left: "status", Op: "==", Right: "200"},
+				{Left: "jsonpath:$.name", Op: "==", Right: "ada", Soft: true},
+			}}},
+			"strict": {{Request: "user", Assert: []Assertion{{Left: "status", Op: "==", Right: "200"}}}},
+		},
+	}
+
+	got, err := New(bundle).CurlScript("flow")
+	if err != nil {
+		t.Fatalf("CurlScript failed: %v", err)
+	}
+	for _, want := range []string{
+		"\nfailures=0\n",
+		`echo 'assertion 1 failed in step '\''user'\'': status == 200' >&2; exit 1; }`,
+		`echo 'assertion 2 failed in step '\''user'\'': jsonpath:$.name == ada' >&2; failures=$((failures + 1)); }`,
+		"if [ \"$failures\" -gt 0 ]; then\n  echo \"$failures checks failed\" >&2\n  exit 1\nfi\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected script to contain %q, got:\n%s", want, got)
+		}
+	}
+
+	got, err = New(bundle).CurlScript("strict")
+	if err != nil {
+		t.Fatalf("CurlScript failed: %v", err)
+	}
+	if strings.Contains(got, "failures") {
+		t.Errorf("expected no failure count without soft checks, got:\n%s", got)
+	}
+}
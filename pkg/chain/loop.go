@@ -0,0 +1,104 @@
+package chain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// loopVariables are bound on every iteration of a foreach or repeat step.
+var loopVariables = []string{"item", "index"}
+
+// executeLoop runs a foreach or repeat step once per iteration, binding
+// {{item}} and {{index}}. The loop body is the step's steps, or the step
+// itself when it has none.
+func (r *Runner) executeLoop(ctx context.Context, step ChainStep, variables map[string]interface{}) error {
+	var items []interface{}
+	if step.Foreach != "" {
+		var err error
+		if items, err = loopItems(step.Foreach, variables); err != nil {
+			return err
+		}
+		fmt.Fprintf(r.log(), "Looping over %d items of '%s'...\n", len(items), variableName(step.Foreach))
+	} else {
+		items = make([]interface{}, step.Repeat)
+		for i := range items {
+			items[i] = i
+		}
+		fmt.Fprintf(r.log(), "Repeating %d times...\n", step.Repeat)
+	}
+
+	body := step.Steps
+	if len(body) == 0 {
+		single := step
+		single.Foreach, single.Repeat, single.Collect = "", 0, nil
+		single.If, single.Else = "", nil
+		body = []ChainStep{single}
+	}
+
+	// Loop variables shadow any outer ones only for the duration of the loop.
+	saved := make(map[string]interface{})
+	for _, name := range loopVariables {
+		if v, ok := variables[name]; ok {
+			saved[name] = v
+		}
+	}
+	defer func() {
+		for _, name := range loopVariables {
+			if v, ok := saved[name]; ok {
+				variables[name] = v
+			} else {
+				delete(variables, name)
+			}
+		}
+	}()
+
+	collected := make(map[string][]interface{}, len(step.Collect))
+	for i, item := range items {
+		variables["item"] = item
+		variables["index"] = i
+		// Clear collected values so a failed extraction is not collected twice.
+		for _, from := range step.Collect {
+			delete(variables, from)
+		}
+
+		if err := r.at(i).executeSteps(ctx, body, variables); err != nil {
+			return fmt.Errorf("iteration %d failed: %w", i, err)
+		}
+
+		for name, from := range step.Collect {
+			collected[name] = append(collected[name], variables[from])
+		}
+	}
+
+	for name := range step.Collect {
+		if collected[name] == nil {
+			collected[name] = []interface{}{}
+		}
+		variables[name] = collected[name]
+	}
+	return nil
+}
+
+// loopItems returns the array held by the foreach variable. Arrays given as
+// JSON text, e.g. from an environment, are decoded.
+func loopItems(foreach string, variables map[string]interface{}) ([]interface{}, error) {
+	name := variableName(foreach)
+	value, ok := lookupVariable(variables, name)
+	if !ok {
+		return nil, fmt.Errorf("foreach variable '%s' is not set", name)
+	}
+
+	switch v := value.(type) {
+	case []interface{}:
+		return v, nil
+	case string:
+		var items []interface{}
+		if err := json.Unmarshal([]byte(v), &items); err != nil {
+			return nil, fmt.Errorf("foreach variable '%s' is not an array: %w", name, err)
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("foreach variable '%s' is not an array", name)
+	}
+}
@@ -0,0 +1,184 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestForeachStep(t *testing.T) {
+	var deleted []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			fmt.Fprint(w, `{"data":[{"id":"a"},{"id":"b"},{"id":"c"}]}`)
+		case http.MethodDelete:
+			deleted = append(deleted, r.URL.Path+"#"+r.URL.Query().Get("i"))
+			fmt.Fprintf(w, `{"deleted":"%s"}`, strings.TrimPrefix(r.URL.Path, "/inventories/"))
+		}
+	}))
+	defer ts.Close()
+
+	bundle := &Bundle{
+		BaseURL: ts.URL,
+		Requests: map[string]Request{
+			"list":   {Method: "GET", URL: "/inventories"},
+			"delete": {Method: "DELETE", URL: "/inventories/{{id}}?i={{index}}"},
+		},
+		Chains: map[string][]ChainStep{
+			"cleanup": {
+				{Request: "list", Extract: map[string]string{"ids": "$.data[*].id"}},
+				{
+					Request:   "delete",
+					Foreach:   "{{ids}}",
+					Variables: map[string]string{"id": "{{item}}"},
+					Extract:   map[string]string{"gone": "$.deleted"},
+					Collect:   map[string]string{"all_gone": "gone"},
+				},
+			},
+		},
+	}
+
+	runner := New(bundle)
+	runner.Out = &bytes.Buffer{}
+	runner.Log = nil
+
+	variables := map[string]interface{}{}
+	if err := runner.executeSteps(context.Background(), bundle.Chains["cleanup"], variables); err != nil {
+		t.Fatalf("executeSteps failed: %v", err)
+	}
+
+	expected := []string{"/inventories/a#0", "/inventories/b#1", "/inventories/c#2"}
+	if !reflect.DeepEqual(deleted, expected) {
+		t.Errorf("expected deletes %v, got %v", expected, deleted)
+	}
+	if got := variables["all_gone"]; !reflect.DeepEqual(got, []interface{}{"a", "b", "c"}) {
+		t.Errorf("expected collected values, got %v", got)
+	}
+	if _, ok := variables["item"]; ok {
+		t.Error("expected item to be unset after the loop")
+	}
+}
+
+func TestRepeatStep(t *testing.T) {
+	var calls int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprint(w, `{}`)
+	}))
+	defer ts.Close()
+
+	bundle := &Bundle{
+		BaseURL:  ts.URL,
+		Requests: map[string]Request{"ping": {Method: "GET", URL: "/ping"}},
+		Chains: map[string][]ChainStep{
+			"flow": {{Repeat: 3, Steps: []ChainStep{{Request: "ping"}, {Request: "ping"}}}},
+		},
+	}
+
+	runner := New(bundle)
+	runner.Out = &bytes.Buffer{}
+	runner.Log = nil
+
+	if err := runner.Run(context.Background(), "flow"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if calls != 6 {
+		t.Errorf("expected 6 requests, got %d", calls)
+	}
+}
+
+func TestForeachNotAnArray(t *testing.T) {
+	bundle := &Bundle{
+		Requests: map[string]Request{"get": {Method: "GET", URL: "/"}},
+		Chains: map[string][]ChainStep{
+			"flow": {{Request: "get", Foreach: "ids"}},
+		},
+	}
+
+	runner := New(bundle)
+	runner.Log = nil
+	runner.Variables["ids"] = "nope"
+
+	err := runner.Run(context.Background(), "flow")
+	if err == nil || !strings.Contains(err.Error(), "foreach variable 'ids' is not an array") {
+		t.Errorf("expected a foreach error, got %v", err)
+	}
+}
+
+func TestCurlScriptLoop(t *testing.T) {
+	bundle := &Bundle{
+		BaseURL: "https://api.etin.dev",
+		Requests: map[string]Request{
+			"delete": {Method: "DELETE", URL: "/inventories/{{item}}"},
+		},
+		Chains: map[string][]ChainStep{
+			"cleanup": {{
+				Request: "delete",
+				Foreach: "ids",
+				Extract: map[string]string{"gone": "$.deleted"},
+				Collect: map[string]string{"all_gone": "gone"},
+			}},
+		},
+	}
+
+	got, err := New(bundle).CurlScript("cleanup")
+	if err != nil {
+		t.Fatalf("CurlScript failed: %v", err)
+	}
+
+	for _, want := range []string{
+		"all_gone='[]'\nindex=0\nwhile IFS= read -r item; do\n  # Step: delete\n",
+		`  all_gone=$(printf '%s' "$all_gone" | jq -c --arg v "${gone}" '. + [$v]')`,
+		"  index=$((index + 1))\ndone < <(printf '%s' \"${ids}\" | jq -rc '.[]')\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected script to contain %q, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestForeachObjects(t *testing.T) {
+	var bodies []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		fmt.Fprint(w, `{}`)
+	}))
+	defer ts.Close()
+
+	bundle := &Bundle{
+		BaseURL:  ts.URL,
+		Requests: map[string]Request{"create": {Method: "POST", URL: "/users", Body: `{"user":{{item}},"tags":{{tags}}}`}},
+		Chains: map[string][]ChainStep{
+			"flow": {{Request: "create", Foreach: "users"}},
+		},
+	}
+	runner := New(bundle)
+	runner.Out = &bytes.Buffer{}
+	runner.Log = nil
+
+	variables := map[string]interface{}{
+		"users": []interface{}{
+			map[string]interface{}{"name": "ada", "age": 36},
+			map[string]interface{}{"name": "grace", "roles": []interface{}{"admin"}},
+		},
+		"tags": []interface{}{"a", "b"},
+	}
+	if err := runner.executeSteps(context.Background(), bundle.Chains["flow"], variables); err != nil {
+		t.Fatalf("executeSteps failed: %v", err)
+	}
+	expected := []string{
+		`{"user":{"age":36,"name":"ada"},"tags":["a","b"]}`,
+		`{"user":{"name":"grace","roles":["admin"]},"tags":["a","b"]}`,
+	}
+	if !reflect.DeepEqual(bodies, expected) {
+		t.Errorf("expected bodies %q, got %q", expected, bodies)
+	}
+}
@@ -0,0 +1,118 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// parallelResult is the outcome of one step of a parallel group.
+type parallelResult struct {
+	out, log  bytes.Buffer
+	report    Report
+	variables map[string]interface{}
+	err       error
+}
+
+// executeParallel runs the steps of a parallel group concurrently. Each step
+// works on its own copy of the variables and writes to its own buffers and
+// report; once all of them finish their output and results are written in
+// step order and the changes they made to the variables, including ones they
+// removed, are merged back. When two steps change the same variable the later
+// step in the group wins. The first step to fail cancels the others, and the
+// group fails with its error.
+func (r *Runner) executeParallel(ctx context.Context, group ChainStep, variables map[string]interface{}) error {
+	steps := group.Parallel
+	limit := group.MaxConcurrency
+	if limit <= 0 || limit > len(steps) {
+		limit = len(steps)
+	}
+
+	fmt.Fprintf(r.log(), "Running %d steps in parallel...\n", len(steps))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	failed := -1
+	var failOnce sync.Once
+
+	results := make([]parallelResult, len(steps))
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := range steps {
+		stepVars := make(map[string]interface{}, len(variables))
+		for k, v := range variables {
+			stepVars[k] = v
+		}
+		results[i].variables = stepVars
+
+		// Steps take a slot in order, and those still waiting for one do
+		// not start after a failure.
+		sem <- struct{}{}
+		if err := ctx.Err(); err != nil {
+			<-sem
+			results[i].err = err
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			res := &results[i]
+			sub := r.at(i)
+			sub.Out = &res.out
+			sub.Log = &res.log
+			if r.Report != nil {
+				sub.Report = &res.report
+			}
+			res.err = sub.executeSteps(ctx, steps[i:i+1], res.variables)
+			if res.err != nil {
+				failOnce.Do(func() {
+					failed = i
+					cancel()
+				})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Only merge what a step changed, so one step's unchanged copy of a
+	// variable does not undo another's update.
+	before := make(map[string]interface{}, len(variables))
+	for k, v := range variables {
+		before[k] = v
+	}
+
+	for i := range results {
+		res := &results[i]
+		if _, err := r.log().Write(res.log.Bytes()); err != nil {
+			return err
+		}
+		if _, err := r.out().Write(res.out.Bytes()); err != nil {
+			return err
+		}
+		if r.Report != nil {
+			r.Report.add(res.report.Steps...)
+		}
+		if res.err != nil {
+			continue
+		}
+		for k, v := range res.variables {
+			if old, ok := before[k]; !ok || !reflect.DeepEqual(old, v) {
+				variables[k] = v
+			}
+		}
+		for k := range before {
+			if _, ok := res.variables[k]; !ok {
+				delete(variables, k)
+			}
+		}
+	}
+	if failed >= 0 {
+		return fmt.Errorf("parallel step %d failed: %w", failed+1, results[failed].err)
+	}
+	// Without a failure the group is only canceled with the chain.
+	return ctx.Err()
+}
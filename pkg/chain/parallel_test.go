@@ -0,0 +1,197 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParallelSteps(t *testing.T) {
+	var active, peak int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		fmt.Fprintf(w, `{"user":"%s"}`, r.URL.Query().Get("name"))
+	}))
+	defer ts.Close()
+
+	bundle := &Bundle{
+		BaseURL: ts.URL,
+		Requests: map[string]Request{
+			"signup": {Method: "GET", URL: "/signup?name={{name}}"},
+		},
+		Chains: map[string][]ChainStep{
+			"flow": {
+				{Parallel: []ChainStep{
+					{Request: "signup", Variables: map[string]string{"name": "a"}, Extract: map[string]string{"user_a": "$.user"}},
+					{Request: "signup", Variables: map[string]string{"name": "b"}, Extract: map[string]string{"user_b": "$.user"}},
+					{Request: "signup", Variables: map[string]string{"name": "c"}, Extract: map[string]string{"user_c": "$.user"}},
+				}},
+				{
+					Request:   "signup",
+					Variables: map[string]string{"name": "{{user_a}}{{user_b}}{{user_c}}"},
+					Assert:    []Assertion{{Left: "{{user_b}}", Op: "==", Right: "b"}},
+				},
+			},
+		},
+	}
+
+	var out, log bytes.Buffer
+	runner := New(bundle)
+	runner.Out = &out
+	runner.Log = &log
+
+	if err := runner.Run(context.Background(), "flow"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	expected := `{"user":"a"}{"user":"b"}{"user":"c"}{"user":"abc"}`
+	if out.String() != expected {
+		t.Errorf("expected output in step order %q, got %q", expected, out.String())
+	}
+	if peak < 2 {
+		t.Errorf("expected steps to run concurrently, peak was %d", peak)
+	}
+	if !strings.Contains(log.String(), "Running 3 steps in parallel...") {
+		t.Errorf("expected parallel group to be logged, got %q", log.String())
+	}
+
+	// A concurrency limit of one runs the group one step at a time.
+	atomic.StoreInt32(&peak, 0)
+	bundle.Chains["flow"][0].MaxConcurrency = 1
+	if err := runner.Run(context.Background(), "flow"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if peak != 1 {
+		t.Errorf("expected max concurrency of 1, peak was %d", peak)
+	}
+}
+
+func TestParallelStepFailure(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"ok":true}`)
+	}))
+	defer ts.Close()
+
+	bundle := &Bundle{
+		BaseURL:  ts.URL,
+		Requests: map[string]Request{"get": {Method: "GET", URL: "/"}},
+		Chains: map[string][]ChainStep{
+			"flow": {{Parallel: []ChainStep{
+				{Request: "get"},
+				{Request: "missing"},
+			}}},
+		},
+	}
+
+	runner := New(bundle)
+	runner.Out = &bytes.Buffer{}
+	runner.Log = nil
+
+	err := runner.Run(context.Background(), "flow")
+	if err == nil || !strings.Contains(err.Error(), "parallel step 2 failed") {
+		t.Errorf("expected the failing step to be reported, got %v", err)
+	}
+}
+
+func TestParallelStepCancelsOthers(t *testing.T) {
+	var later int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		default:
+			atomic.AddInt32(&later, 1)
+		}
+	}))
+	defer ts.Close()
+
+	bundle := &Bundle{
+		BaseURL: ts.URL,
+		Requests: map[string]Request{
+			// Nothing listens on port 1, so the request fails at once.
+			"fail":  {Method: "GET", URL: "http://127.0.0.1:1/fail"},
+			"slow":  {Method: "GET", URL: "/slow"},
+			"later": {Method: "GET", URL: "/later"},
+		},
+		Chains: map[string][]ChainStep{
+			"flow": {{MaxConcurrency: 2, Parallel: []ChainStep{
+				{Request: "slow"},
+				{Request: "fail"},
+				{Request: "later"},
+			}}},
+		},
+	}
+	runner := New(bundle)
+	runner.Out = &bytes.Buffer{}
+	runner.Log = nil
+
+	start := time.Now()
+	err := runner.Run(context.Background(), "flow")
+	if err == nil || !strings.Contains(err.Error(), "parallel step 2 failed") || !strings.Contains(err.Error(), "request failed") {
+		t.Errorf("expected the failing step to be reported, got %v", err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("expected the slow step to be canceled, the group took %v", d)
+	}
+	if n := atomic.LoadInt32(&later); n != 0 {
+		t.Errorf("expected the waiting step not to start after the failure, it ran %d times", n)
+	}
+}
+
+func TestParallelMergesChanges(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"token":"","name":"%s"}`, r.URL.Query().Get("name"))
+	}))
+	defer ts.Close()
+
+	bundle := &Bundle{
+		BaseURL:  ts.URL,
+		Requests: map[string]Request{"get": {Method: "GET", URL: "/?name={{name}}"}},
+	}
+	runner := New(bundle)
+	runner.Out = &bytes.Buffer{}
+	runner.Log = nil
+	runner.Strict = false
+
+	group := ChainStep{Parallel: []ChainStep{
+		// Overwrites token with an empty value.
+		{Request: "get", Extract: map[string]string{"token": "$.token"}},
+		// Collecting id clears it before each iteration, and the body does not
+		// set it again, so the step removes it.
+		{Foreach: "items", Collect: map[string]string{"ids": "id"}, Steps: []ChainStep{
+			{Request: "get", Extract: map[string]string{"id": "$.missing"}},
+		}},
+		// Both steps set name; the later one wins.
+		{Request: "get", Variables: map[string]string{"name": "a"}, Extract: map[string]string{"name": "$.name"}},
+		{Request: "get", Variables: map[string]string{"name": "b"}, Extract: map[string]string{"name": "$.name"}},
+	}}
+	variables := map[string]interface{}{"token": "old", "id": 1, "name": "x", "kept": true, "items": []interface{}{1}}
+	if err := runner.executeParallel(context.Background(), group, variables); err != nil {
+		t.Fatalf("executeParallel failed: %v", err)
+	}
+	if v, ok := variables["token"]; !ok || v != "" {
+		t.Errorf("expected token to be emptied, got %v", v)
+	}
+	if v, ok := variables["id"]; ok {
+		t.Errorf("expected id to be removed, got %v", v)
+	}
+	if variables["name"] != "b" || variables["kept"] != true {
+		t.Errorf("unexpected variables %v", variables)
+	}
+}
@@ -0,0 +1,118 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultPollInterval = time.Second
+	defaultPollAttempts = 10
+)
+
+// Poll re-runs a step until its assertions pass.
+type Poll struct {
+	// Interval is the wait between attempts, one second by default.
+	Interval time.Duration `mapstructure:"interval"`
+	// MaxAttempts limits the number of attempts. Without it or a Timeout,
+	// a step is attempted ten times.
+	MaxAttempts int `mapstructure:"max_attempts"`
+	// Timeout limits the total time spent polling.
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+func (p *Poll) interval() time.Duration {
+	if p.Interval <= 0 {
+		return defaultPollInterval
+	}
+	return p.Interval
+}
+
+// maxAttempts returns the attempt limit, or zero when only the timeout applies.
+func (p *Poll) maxAttempts() int {
+	if p.MaxAttempts <= 0 && p.Timeout <= 0 {
+		return defaultPollAttempts
+	}
+	return p.MaxAttempts
+}
+
+// pollStep runs the step until its assertions pass, then writes the final
+// response to the output. If they never pass the last response is written and
+// the last failed assertion returned.
+func (r *Runner) pollStep(ctx context.Context, step ChainStep, variables map[string]interface{}) (*response, error) {
+	if len(step.Assert) == 0 {
+		return nil, fmt.Errorf("step '%s' polls 'until' but has no 'assert' condition", step.Request)
+	}
+
+	poll := step.Until
+	interval := poll.interval()
+	maxAttempts := poll.maxAttempts()
+
+	parent := ctx
+	if poll.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, poll.Timeout)
+		defer cancel()
+	}
+
+	// Only the last response is shown, the earlier ones are superseded.
+	var last []byte
+	var resp *response
+	var condErr error
+	for attempt := 1; ; attempt++ {
+		var out bytes.Buffer
+		sub := *r
+		sub.Out = &out
+
+		attemptResp, err := sub.runStep(ctx, step, variables)
+		// A value that is missing until the condition holds is expected
+		// while polling, so it only fails the attempt.
+		var extractErr *extractionError
+		if errors.As(err, &extractErr) {
+			resp, last = attemptResp, out.Bytes()
+			condErr = err
+		} else if err != nil {
+			// A request cut short by the polling timeout ends polling like
+			// any other unmet condition.
+			if condErr != nil && ctx.Err() != nil && parent.Err() == nil {
+				break
+			}
+			return attemptResp, fmt.Errorf("step '%s' failed: %w", step.Request, err)
+		} else {
+			resp, last = attemptResp, out.Bytes()
+			condErr = executeAssertions(step.Assert, variables, attemptResp)
+		}
+
+		if condErr == nil {
+			_, err := r.out().Write(last)
+			return resp, err
+		}
+		if maxAttempts > 0 && attempt >= maxAttempts {
+			if _, err := r.out().Write(last); err != nil {
+				return resp, err
+			}
+			return resp, fmt.Errorf("condition not met in step '%s' after %d attempts (last status %d): %w", step.Request, attempt, resp.status(), condErr)
+		}
+
+		fmt.Fprintf(r.log(), "Condition not met (attempt %d), polling again in %s...\n", attempt, interval)
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+			continue
+		}
+		if parent.Err() != nil {
+			return resp, parent.Err()
+		}
+		break
+	}
+
+	if _, err := r.out().Write(last); err != nil {
+		return resp, err
+	}
+	return resp, fmt.Errorf("condition not met in step '%s' within %s (last status %d): %w", step.Request, poll.Timeout, resp.status(), condErr)
+}
@@ -0,0 +1,98 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newJobServer(doneAfter int32) (*httptest.Server, *int32) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := "running"
+		if atomic.AddInt32(&calls, 1) >= doneAfter {
+			status = "done"
+		}
+		fmt.Fprintf(w, `{"status":"%s"}`, status)
+	}))
+	return ts, &calls
+}
+
+func jobBundle(url string, until *Poll) *Bundle {
+	return &Bundle{
+		BaseURL:  url,
+		Requests: map[string]Request{"job": {Method: "GET", URL: "/jobs/1"}},
+		Chains: map[string][]ChainStep{
+			"wait": {{
+				Request: "job",
+				Extract: map[string]string{"status": "$.status"},
+				Assert:  []Assertion{{Left: "{{status}}", Op: "==", Right: "done"}},
+				Until:   until,
+			}},
+		},
+	}
+}
+
+func TestPollUntilDone(t *testing.T) {
+	ts, calls := newJobServer(3)
+	defer ts.Close()
+
+	var out, log bytes.Buffer
+	runner := New(jobBundle(ts.URL, &Poll{Interval: time.Millisecond, MaxAttempts: 5}))
+	runner.Out = &out
+	runner.Log = &log
+
+	if err := runner.Run(context.Background(), "wait"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if *calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", *calls)
+	}
+	if out.String() != `{"status":"done"}` {
+		t.Errorf("expected only the final response, got %q", out.String())
+	}
+	if !strings.Contains(log.String(), "Condition not met (attempt 2)") {
+		t.Errorf("expected polling to be logged, got %q", log.String())
+	}
+}
+
+func TestPollMaxAttempts(t *testing.T) {
+	ts, calls := newJobServer(10)
+	defer ts.Close()
+
+	var out bytes.Buffer
+	runner := New(jobBundle(ts.URL, &Poll{Interval: time.Millisecond, MaxAttempts: 2}))
+	runner.Out = &out
+	runner.Log = nil
+
+	err := runner.Run(context.Background(), "wait")
+	if err == nil || !strings.Contains(err.Error(), "condition not met in step 'job' after 2 attempts (last status 200)") {
+		t.Errorf("expected the condition to fail, got %v", err)
+	}
+	if *calls != 2 {
+		t.Errorf("expected 2 attempts, got %d", *calls)
+	}
+	if out.String() != `{"status":"running"}` {
+		t.Errorf("expected the last response, got %q", out.String())
+	}
+}
+
+func TestPollTimeout(t *testing.T) {
+	ts, _ := newJobServer(1000)
+	defer ts.Close()
+
+	runner := New(jobBundle(ts.URL, &Poll{Interval: 10 * time.Millisecond, Timeout: 50 * time.Millisecond}))
+	runner.Out = &bytes.Buffer{}
+	runner.Log = nil
+
+	err := runner.Run(context.Background(), "wait")
+	if err == nil || !strings.Contains(err.Error(), "within 50ms") {
+		t.Errorf("expected the poll to time out, got %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package chain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Format selects how response bodies are rendered.
+type Format string
+
+const (
+	// FormatRaw writes bodies exactly as received.
+	FormatRaw Format = "raw"
+	// FormatAuto pretty-prints bodies whose Content-Type is JSON.
+	FormatAuto Format = "auto"
+	// FormatJSON pretty-prints any body that is valid JSON.
+	FormatJSON Format = "json"
+	// FormatYAML converts any body that is valid JSON to YAML.
+	FormatYAML Format = "yaml"
+)
+
+// ParseFormat validates a format name.
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(name); f {
+	case FormatRaw, FormatAuto, FormatJSON, FormatYAML:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown format '%s', expected json, yaml or raw", name)
+	}
+}
+
+const (
+	colorReset  = "\x1b[0m"
+	colorKey    = "\x1b[34;1m"
+	colorString = "\x1b[32m"
+	colorNumber = "\x1b[36m"
+	colorBool   = "\x1b[33m"
+	colorNull   = "\x1b[90m"
+)
+
+// render writes body to w in the runner's format. Bodies that cannot be
+// converted are written as they are.
+func (r *Runner) render(w io.Writer, body []byte, contentType string) error {
+	format := r.Format
+	if format == FormatAuto {
+		format = FormatRaw
+		if isJSONContentType(contentType) {
+			format = FormatJSON
+		}
+	}
+
+	switch format {
+	case FormatJSON:
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, bytes.TrimSpace(body), "", "  "); err == nil {
+			if r.Color {
+				_, err := io.WriteString(w, colorizeJSON(indented.String()))
+				return err
+			}
+			_, err := w.Write(indented.Bytes())
+			return err
+		}
+	case FormatYAML:
+		if converted, err := jsonToYAML(body); err == nil {
+			_, err := w.Write(bytes.TrimSuffix(converted, []byte("\n")))
+			return err
+		}
+	}
+
+	_, err := w.Write(body)
+	return err
+}
+
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// jsonToYAML converts a JSON document to block style YAML, keeping key order.
+func jsonToYAML(body []byte) ([]byte, error) {
+	if !json.Valid(body) {
+		return nil, fmt.Errorf("body is not valid JSON")
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(body, &node); err != nil {
+		return nil, err
+	}
+	clearStyle(&node)
+
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// clearStyle drops the flow style and quoting JSON is parsed with.
+func clearStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearStyle(child)
+	}
+}
+
+// colorizeJSON highlights indented JSON. Object keys are told apart from
+// string values by the colon that follows them.
+func colorizeJSON(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '"':
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end++
+			if end > len(s) {
+				end = len(s)
+			}
+			color := colorString
+			if end < len(s) && s[end] == ':' {
+				color = colorKey
+			}
+			b.WriteString(color + s[i:end] + colorReset)
+			i = end
+		case c == '-' || (c >= '0' && c <= '9'):
+			end := i + 1
+			for end < len(s) && strings.IndexByte("0123456789.eE+-", s[end]) >= 0 {
+				end++
+			}
+			b.WriteString(colorNumber + s[i:end] + colorReset)
+			i = end
+		case strings.HasPrefix(s[i:], "true"):
+			b.WriteString(colorBool + "true" + colorReset)
+			i += 4
+		case strings.HasPrefix(s[i:], "false"):
+			b.WriteString(colorBool + "false" + colorReset)
+			i += 5
+		case strings.HasPrefix(s[i:], "null"):
+			b.WriteString(colorNull + "null" + colorReset)
+			i += 4
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
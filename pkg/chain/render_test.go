@@ -0,0 +1,93 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	body := []byte(`{"name":"ada","tags":["a"],"age":36}`)
+
+	tests := []struct {
+		format      Format
+		contentType string
+		expected    string
+	}{
+		{"", "application/json", `{"name":"ada","tags":["a"],"age":36}`},
+		{FormatRaw, "application/json", `{"name":"ada","tags":["a"],"age":36}`},
+		{FormatAuto, "text/plain", `{"name":"ada","tags":["a"],"age":36}`},
+		{FormatAuto, "application/problem+json; charset=utf-8", "{\n  \"name\": \"ada\",\n  \"tags\": [\n    \"a\"\n  ],\n  \"age\": 36\n}"},
+		{FormatJSON, "text/plain", "{\n  \"name\": \"ada\",\n  \"tags\": [\n    \"a\"\n  ],\n  \"age\": 36\n}"},
+		{FormatYAML, "application/json", "name: ada\ntags:\n  - a\nage: 36"},
+	}
+
+	for _, tt := range tests {
+		runner := &Runner{Format: tt.format}
+		var out bytes.Buffer
+		if err := runner.render(&out, body, tt.contentType); err != nil {
+			t.Fatalf("render failed: %v", err)
+		}
+		if out.String() != tt.expected {
+			t.Errorf("render(%q, %q) = %q, expected %q", tt.format, tt.contentType, out.String(), tt.expected)
+		}
+	}
+}
+
+func TestRenderNonJSONFallsBack(t *testing.T) {
+	runner := &Runner{Format: FormatYAML}
+	var out bytes.Buffer
+	if err := runner.render(&out, []byte("<html></html>"), "text/html"); err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if out.String() != "<html></html>" {
+		t.Errorf("expected body unchanged, got %q", out.String())
+	}
+}
+
+func TestColorizeJSON(t *testing.T) {
+	got := colorizeJSON(`{"a": "b:c", "n": -1.5, "ok": true, "x": null}`)
+	expected := `{` + colorKey + `"a"` + colorReset + `: ` + colorString + `"b:c"` + colorReset +
+		`, ` + colorKey + `"n"` + colorReset + `: ` + colorNumber + `-1.5` + colorReset +
+		`, ` + colorKey + `"ok"` + colorReset + `: ` + colorBool + `true` + colorReset +
+		`, ` + colorKey + `"x"` + colorReset + `: ` + colorNull + `null` + colorReset + `}`
+	if got != expected {
+		t.Errorf("unexpected colorized JSON %q", got)
+	}
+}
+
+func TestChainCaptureKeepsRawBody(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":7}`)
+	}))
+	defer ts.Close()
+
+	bundle := &Bundle{
+		BaseURL:  ts.URL,
+		Requests: map[string]Request{"get": {Method: "GET", URL: "/"}},
+		Chains: map[string][]ChainStep{
+			"flow": {{
+				Request: "get",
+				Extract: map[string]string{"id": "$.id"},
+				Assert:  []Assertion{{Left: "{{id}}", Op: "==", Right: "7"}},
+			}},
+		},
+	}
+
+	var out bytes.Buffer
+	runner := New(bundle)
+	runner.Out = &out
+	runner.Log = nil
+	runner.Format = FormatYAML
+
+	if err := runner.Run(context.Background(), "flow"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if out.String() != "id: 7" {
+		t.Errorf("expected YAML output, got %q", out.String())
+	}
+}
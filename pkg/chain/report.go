@@ -0,0 +1,370 @@
+package chain
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Report collects the outcome of every step a Runner runs, for rendering as
+// JUnit XML or JSON.
+type Report struct {
+	// Name is the chain or request that was run.
+	Name     string
+	Duration time.Duration
+	// Error is the error the run failed with, if any.
+	Error string
+	Steps []StepResult
+
+	mu sync.Mutex
+}
+
+// StepResult is the outcome of a single step.
+type StepResult struct {
+	// Chain is the chain the step belongs to, empty for a single request.
+	Chain    string
+	Request  string
+	Duration time.Duration
+	// Status is the response status code, or zero without a response.
+	Status     int
+	Assertions []AssertionResult
+	// Skipped is set for steps whose 'if' condition was false.
+	Skipped bool
+	// Recovered is set when the step failed but an on_error branch ran.
+	Recovered bool
+	Error     string
+}
+
+// AssertionResult is an assertion with its substituted values.
+type AssertionResult struct {
+	Left   string
+	Op     string
+	Right  string
+	Passed bool
+	Soft   bool
+}
+
+// Failed reports whether the step failed the run.
+func (s StepResult) Failed() bool {
+	return s.Error != "" && !s.Recovered
+}
+
+func (rep *Report) add(results ...StepResult) {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	rep.Steps = append(rep.Steps, results...)
+}
+
+// begin resets the report for a run of the named chain or request and returns
+// a function that records how the run ended.
+func (rep *Report) begin(name string) func(err error) {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	rep.Name, rep.Duration, rep.Error, rep.Steps = name, 0, "", nil
+
+	start := time.Now()
+	return func(err error) {
+		rep.mu.Lock()
+		defer rep.mu.Unlock()
+		rep.Duration = time.Since(start)
+		if err != nil {
+			rep.Error = err.Error()
+		}
+	}
+}
+
+// record adds the outcome of a step to the runner's report, if it has one.
+func (r *Runner) record(step ChainStep, start time.Time, resp *response, assertions []AssertionResult, err error) {
+	if r.Report != nil {
+		r.Report.add(r.stepResult(step, start, resp, assertions, err))
+	}
+}
+
+// recordRecovered adds a step whose error was handled by on_error.
+func (r *Runner) recordRecovered(step ChainStep, start time.Time, resp *response, err error) {
+	if r.Report != nil {
+		result := r.stepResult(step, start, resp, nil, err)
+		result.Recovered = true
+		r.Report.add(result)
+	}
+}
+
+func (r *Runner) stepResult(step ChainStep, start time.Time, resp *response, assertions []AssertionResult, err error) StepResult {
+	result := StepResult{
+		Chain:      r.chainName(),
+		Request:    stepName(step),
+		Duration:   time.Since(start),
+		Status:     resp.status(),
+		Assertions: assertions,
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// recordSkipped adds a step whose condition was false to the report.
+func (r *Runner) recordSkipped(step ChainStep) {
+	if r.Report != nil {
+		r.Report.add(StepResult{Chain: r.chainName(), Request: stepName(step), Skipped: true})
+	}
+}
+
+// chainName returns the chain being run, or an empty string outside a chain.
+func (r *Runner) chainName() string {
+	if len(r.callStack) == 0 {
+		return ""
+	}
+	return r.callStack[len(r.callStack)-1]
+}
+
+func stepName(step ChainStep) string {
+	switch {
+	case step.Request != "":
+		return step.Request
+	case step.Chain != "":
+		return "chain " + step.Chain
+	case len(step.Parallel) > 0:
+		return "parallel"
+	case step.Foreach != "":
+		return "foreach " + variableName(step.Foreach)
+	case step.Repeat > 0:
+		return fmt.Sprintf("repeat %d", step.Repeat)
+	default:
+		return "steps"
+	}
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+
+	duration time.Duration
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Skipped   *struct{}     `xml:"skipped"`
+	Failure   *junitMessage `xml:"failure"`
+	Error     *junitMessage `xml:"error"`
+	SystemOut *junitOutput  `xml:"system-out"`
+}
+
+type junitOutput struct {
+	Text string `xml:",cdata"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report as JUnit XML with a test suite per chain and a
+// test case per step. Failed assertions are failures, and steps that failed
+// without a response or for other reasons are errors. If the run failed
+// outside of any step, the error is reported as a test case of its own.
+func (rep *Report) WriteJUnit(w io.Writer) error {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	suites := junitTestSuites{Name: rep.Name, Time: seconds(rep.Duration)}
+	index := make(map[string]int)
+	failed := false
+	for _, s := range rep.Steps {
+		suiteName := s.Chain
+		if suiteName == "" {
+			suiteName = rep.Name
+		}
+		i, ok := index[suiteName]
+		if !ok {
+			i = len(suites.Suites)
+			index[suiteName] = i
+			suites.Suites = append(suites.Suites, junitTestSuite{Name: suiteName})
+		}
+		suite := &suites.Suites[i]
+
+		tc := junitTestCase{ClassName: suiteName, Name: s.Request, Time: seconds(s.Duration)}
+		if out := s.output(); out != "" {
+			tc.SystemOut = &junitOutput{Text: out}
+		}
+		suite.Tests++
+		suite.duration += s.Duration
+		switch {
+		case s.Skipped:
+			tc.Skipped = &struct{}{}
+			suite.Skipped++
+		case s.Failed() && s.assertionFailed():
+			tc.Failure = &junitMessage{Message: s.Error, Type: "assertion", Text: s.Error}
+			suite.Failures++
+			failed = true
+		case s.Failed():
+			tc.Error = &junitMessage{Message: s.Error, Text: s.Error}
+			suite.Errors++
+			failed = true
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	if rep.Error != "" && !failed {
+		suites.Suites = append(suites.Suites, junitTestSuite{
+			Name:     rep.Name,
+			Tests:    1,
+			Errors:   1,
+			duration: rep.Duration,
+			Cases: []junitTestCase{{
+				ClassName: rep.Name,
+				Name:      rep.Name,
+				Time:      seconds(rep.Duration),
+				Error:     &junitMessage{Message: rep.Error, Text: rep.Error},
+			}},
+		})
+	}
+
+	for i := range suites.Suites {
+		suite := &suites.Suites[i]
+		suite.Time = seconds(suite.duration)
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		suites.Skipped += suite.Skipped
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// assertionFailed reports whether the step failed one of its assertions.
+func (s StepResult) assertionFailed() bool {
+	for _, a := range s.Assertions {
+		if !a.Passed {
+			return true
+		}
+	}
+	return false
+}
+
+// output describes the step's status and assertions for the JUnit system-out.
+func (s StepResult) output() string {
+	if s.Skipped {
+		return ""
+	}
+	var out string
+	if s.Status != 0 {
+		out = fmt.Sprintf("status %d\n", s.Status)
+	}
+	for i, a := range s.Assertions {
+		result := "passed"
+		if !a.Passed {
+			result = "failed"
+		}
+		if a.Soft {
+			result += " (soft)"
+		}
+		out += fmt.Sprintf("assertion %d %s: '%s' %s '%s'\n", i+1, result, a.Left, a.Op, a.Right)
+	}
+	if s.Recovered {
+		out += fmt.Sprintf("recovered by on_error: %s\n", s.Error)
+	}
+	return out
+}
+
+type jsonReport struct {
+	Name       string           `json:"name"`
+	Passed     bool             `json:"passed"`
+	DurationMS float64          `json:"duration_ms"`
+	Error      string           `json:"error,omitempty"`
+	Steps      []jsonStepResult `json:"steps"`
+}
+
+type jsonStepResult struct {
+	Chain      string          `json:"chain,omitempty"`
+	Request    string          `json:"request"`
+	DurationMS float64         `json:"duration_ms"`
+	Status     int             `json:"status,omitempty"`
+	Assertions []jsonAssertion `json:"assertions,omitempty"`
+	Skipped    bool            `json:"skipped,omitempty"`
+	Recovered  bool            `json:"recovered,omitempty"`
+	Passed     bool            `json:"passed"`
+	Error      string          `json:"error,omitempty"`
+}
+
+type jsonAssertion struct {
+	Left   string `json:"left"`
+	Op     string `json:"op"`
+	Right  string `json:"right"`
+	Passed bool   `json:"passed"`
+	Soft   bool   `json:"soft,omitempty"`
+}
+
+// WriteJSON writes the report as an indented JSON document.
+func (rep *Report) WriteJSON(w io.Writer) error {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	doc := jsonReport{
+		Name:       rep.Name,
+		Passed:     rep.Error == "",
+		DurationMS: milliseconds(rep.Duration),
+		Error:      rep.Error,
+		Steps:      make([]jsonStepResult, 0, len(rep.Steps)),
+	}
+	for _, s := range rep.Steps {
+		step := jsonStepResult{
+			Chain:      s.Chain,
+			Request:    s.Request,
+			DurationMS: milliseconds(s.Duration),
+			Status:     s.Status,
+			Skipped:    s.Skipped,
+			Recovered:  s.Recovered,
+			Passed:     !s.Failed(),
+			Error:      s.Error,
+		}
+		for _, a := range s.Assertions {
+			step.Assertions = append(step.Assertions, jsonAssertion(a))
+		}
+		doc.Steps = append(doc.Steps, step)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,127 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		fmt.Fprint(w, `{"count":3}`)
+	}))
+	defer ts.Close()
+
+	bundle := &Bundle{
+		BaseURL: ts.URL,
+		Requests: map[string]Request{
+			"list":    {Method: "GET", URL: "/list"},
+			"missing": {Method: "GET", URL: "/missing"},
+		},
+		Chains: map[string][]ChainStep{
+			"setup": {{Request: "list"}},
+			"smoke": {
+				{Chain: "setup"},
+				{
+					Request: "list",
+					Extract: map[string]string{"count": "$.count"},
+					Assert:  []Assertion{{Left: "{{count}}", Op: ">=", Right: "1"}},
+				},
+				{Request: "list", If: "{{count}} > 5"},
+				{
+					Request: "missing",
+					Assert:  []Assertion{{Left: "{{count}}", Op: "==", Right: "4"}},
+				},
+			},
+		},
+	}
+
+	runner := New(bundle)
+	runner.Out = &bytes.Buffer{}
+	runner.Log = nil
+	runner.Report = &Report{}
+
+	if err := runner.Run(context.Background(), "smoke"); err == nil {
+		t.Fatal("expected the chain to fail")
+	}
+
+	rep := runner.Report
+	if rep.Name != "smoke" || rep.Error == "" || len(rep.Steps) != 4 {
+		t.Fatalf("unexpected report %+v", rep)
+	}
+	want := []struct {
+		chain, request string
+		status         int
+		skipped        bool
+		failed         bool
+	}{
+		{"setup", "list", 200, false, false},
+		{"smoke", "list", 200, false, false},
+		{"smoke", "list", 0, true, false},
+		{"smoke", "missing", 404, false, true},
+	}
+	for i, w := range want {
+		s := rep.Steps[i]
+		if s.Chain != w.chain || s.Request != w.request || s.Status != w.status || s.Skipped != w.skipped || s.Failed() != w.failed {
+			t.Errorf("step %d: expected %+v, got %+v", i+1, w, s)
+		}
+	}
+	if a := rep.Steps[3].Assertions; len(a) != 1 || a[0].Left != "3" || a[0].Passed {
+		t.Errorf("expected the failed assertion to be recorded, got %+v", a)
+	}
+
+	var junit bytes.Buffer
+	if err := rep.WriteJUnit(&junit); err != nil {
+		t.Fatalf("WriteJUnit failed: %v", err)
+	}
+	var suites junitTestSuites
+	if err := xml.Unmarshal(junit.Bytes(), &suites); err != nil {
+		t.Fatalf("invalid JUnit XML: %v\n%s", err, junit.String())
+	}
+	if suites.Tests != 4 || suites.Failures != 1 || suites.Skipped != 1 || len(suites.Suites) != 2 {
+		t.Errorf("unexpected JUnit totals:\n%s", junit.String())
+	}
+	if !strings.Contains(junit.String(), "assertion 1 failed: '3' == '4'") {
+		t.Errorf("expected the assertion result in the JUnit report:\n%s", junit.String())
+	}
+
+	var doc jsonReport
+	var out bytes.Buffer
+	if err := rep.WriteJSON(&out); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON report: %v", err)
+	}
+	if doc.Passed || len(doc.Steps) != 4 || doc.Steps[3].Passed || doc.Steps[3].Status != 404 {
+		t.Errorf("unexpected JSON report:\n%s", out.String())
+	}
+}
+
+func TestReportRunError(t *testing.T) {
+	runner := New(&Bundle{Chains: map[string][]ChainStep{"loop": {{Foreach: "items", Request: "list"}}}})
+	runner.Out = &bytes.Buffer{}
+	runner.Log = nil
+	runner.Report = &Report{}
+
+	if err := runner.Run(context.Background(), "loop"); err == nil {
+		t.Fatal("expected the chain to fail")
+	}
+
+	var junit bytes.Buffer
+	if err := runner.Report.WriteJUnit(&junit); err != nil {
+		t.Fatalf("WriteJUnit failed: %v", err)
+	}
+	if !strings.Contains(junit.String(), `errors="1"`) || !strings.Contains(junit.String(), "items") {
+		t.Errorf("expected the run error as a test case:\n%s", junit.String())
+	}
+}
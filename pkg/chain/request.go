@@ -0,0 +1,376 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// preparedRequest is a request with the base URL and headers of the bundle and
+// selected environment applied.
+type preparedRequest struct {
+	Method string
+	URL    string
+	Header http.Header
+	// Body is the literal request body, unless BodyFile names a file to send.
+	Body     string
+	BodyFile string
+}
+
+// unresolved returns the names of placeholders left in the request, sorted.
+func (p preparedRequest) unresolved() []string {
+	texts := []string{p.URL, p.Body}
+	for _, values := range p.Header {
+		texts = append(texts, values...)
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, text := range texts {
+		for _, m := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+			if name := m[1]; !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// prepare applies the bundle and environment to a request. Variables are only
+// substituted when vars is non-nil.
+func (r *Runner) prepare(opts Request, vars map[string]interface{}) (preparedRequest, error) {
+	env, err := r.environment()
+	if err != nil {
+		return preparedRequest{}, err
+	}
+
+	if vars != nil {
+		opts = substituteRequest(opts, vars)
+	}
+
+	// Determine URL
+	url := opts.URL
+	baseURL := r.Bundle.BaseURL
+	if env.BaseURL != "" {
+		baseURL = env.BaseURL
+	}
+
+	// If URL does not start with http(s), prepend base URL
+	if !strings.HasPrefix(url, "http") && baseURL != "" {
+		if !strings.HasSuffix(baseURL, "/") && !strings.HasPrefix(url, "/") {
+			url = baseURL + "/" + url
+		} else if strings.HasSuffix(baseURL, "/") && strings.HasPrefix(url, "/") {
+			url = baseURL + strings.TrimPrefix(url, "/")
+		} else {
+			url = baseURL + url
+		}
+	}
+
+	prepared := preparedRequest{
+		Method: opts.Method,
+		URL:    url,
+		Header: make(http.Header),
+	}
+
+	if opts.Body != "" {
+		if strings.HasPrefix(opts.Body, "@") {
+			// Explicit file path
+			prepared.BodyFile = strings.TrimPrefix(opts.Body, "@")
+		} else if _, err := os.Stat(opts.Body); err == nil {
+			prepared.BodyFile = opts.Body
+		} else {
+			// It's a string
+			prepared.Body = opts.Body
+		}
+	}
+
+	// Add Headers
+	if !opts.NoHeaders {
+		for k, v := range r.Bundle.Headers {
+			if vars != nil {
+				v = substitute(v, vars)
+			}
+			prepared.Header.Add(k, v)
+		}
+		// Environment headers override bundle headers
+		for k, v := range env.Headers {
+			if vars != nil {
+				v = substitute(v, vars)
+			}
+			prepared.Header.Set(k, v)
+		}
+	}
+
+	for _, h := range opts.Headers {
+		parts := strings.SplitN(h, ":", 2)
+		if len(parts) == 2 {
+			key := strings.TrimSpace(parts[0])
+			val := strings.TrimSpace(parts[1])
+			prepared.Header.Set(key, val)
+		}
+	}
+
+	return prepared, nil
+}
+
+// response is a received response with its body read.
+type response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// Duration is how long the last attempt took, including reading the body.
+	Duration time.Duration
+}
+
+// status returns the status code, or zero when no response was received.
+func (resp *response) status() int {
+	if resp == nil {
+		return 0
+	}
+	return resp.StatusCode
+}
+
+// cookie returns the value of a cookie set by the response.
+func (resp *response) cookie(name string) (string, bool) {
+	for _, c := range (&http.Response{Header: resp.Header}).Cookies() {
+		if c.Name == name {
+			return c.Value, true
+		}
+	}
+	return "", false
+}
+
+// makeRequest sends the request and writes the rendered response to the
+// runner's output. Placeholders left in the request and in the bundle and
+// environment headers are filled in from vars, or from the bundle and
+// environment variables when vars is nil. The response is returned whenever
+// one was received, even alongside an error.
+func (r *Runner) makeRequest(ctx context.Context, opts Request, vars map[string]interface{}) (*response, error) {
+	if vars == nil {
+		configVars, err := r.configVariables()
+		if err != nil {
+			return nil, err
+		}
+		vars = configVars
+	}
+
+	prepared, err := r.prepare(opts, vars)
+	if err != nil {
+		return nil, err
+	}
+	if r.Strict {
+		if missing := prepared.unresolved(); len(missing) > 0 {
+			return nil, fmt.Errorf("unresolved variables: %s", strings.Join(missing, ", "))
+		}
+	}
+
+	policy := r.retryPolicy(opts)
+	timeout := r.timeout(opts)
+
+	var resp *http.Response
+	var body []byte
+	var elapsed time.Duration
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		resp, body, err = r.send(ctx, prepared, timeout)
+		elapsed = time.Since(start)
+		if attempt >= policy.attempts() || ctx.Err() != nil || !policy.retryable(resp, err) {
+			break
+		}
+
+		delay := policy.delay(attempt, resp)
+		if err != nil {
+			fmt.Fprintf(r.log(), "Attempt %d/%d failed: %v, retrying in %s...\n", attempt, policy.attempts(), err, delay.Round(time.Millisecond))
+		} else {
+			fmt.Fprintf(r.log(), "Attempt %d/%d returned %d, retrying in %s...\n", attempt, policy.attempts(), resp.StatusCode, delay.Round(time.Millisecond))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+	if err != nil {
+		if resp != nil {
+			return &response{StatusCode: resp.StatusCode, Header: resp.Header, Duration: elapsed}, err
+		}
+		return nil, err
+	}
+	received := &response{StatusCode: resp.StatusCode, Header: resp.Header, Body: body, Duration: elapsed}
+
+	// The head goes straight to the output so it never reaches extraction.
+	if r.Include || r.Verbose {
+		writeResponseHead(r.out(), resp)
+	}
+
+	if r.Select != "" {
+		if err := r.renderSelection(r.out(), body, r.Select); err != nil {
+			// Selecting only changes what is shown, so a step it does not
+			// apply to, such as one answering 204, does not fail the chain.
+			var selErr *selectionError
+			if r.chainName() == "" || !errors.As(err, &selErr) {
+				return received, err
+			}
+			fmt.Fprintf(r.log(), "Warning: %v\n", err)
+		}
+	} else if err := r.render(r.out(), body, resp.Header.Get("Content-Type")); err != nil {
+		return received, fmt.Errorf("failed to write body: %w", err)
+	}
+
+	return received, nil
+}
+
+// send makes a single attempt at the request and reads the whole response
+// body within the timeout.
+func (r *Runner) send(ctx context.Context, prepared preparedRequest, timeout time.Duration) (*http.Response, []byte, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var reqBody io.Reader
+	if prepared.BodyFile != "" {
+		f, err := os.Open(prepared.BodyFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open body file: %w", err)
+		}
+		defer f.Close()
+		reqBody = f
+	} else if prepared.Body != "" {
+		reqBody = strings.NewReader(prepared.Body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, prepared.Method, prepared.URL, reqBody)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header = prepared.Header.Clone()
+
+	if r.Verbose {
+		writeRequestHead(r.log(), req)
+	}
+
+	resp, err := r.client().Do(req)
+	if err != nil {
+		return nil, nil, &transportError{fmt.Errorf("request failed: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp, nil, &transportError{fmt.Errorf("failed to read body: %w", err)}
+	}
+	return resp, body, nil
+}
+
+// transportError is a request that failed without a complete response, such
+// as a refused connection or a timeout.
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string { return e.err.Error() }
+func (e *transportError) Unwrap() error { return e.err }
+
+// timeout returns the most specific timeout for a request.
+func (r *Runner) timeout(opts Request) time.Duration {
+	switch {
+	case r.Timeout > 0:
+		return r.Timeout
+	case opts.Timeout > 0:
+		return opts.Timeout
+	default:
+		return r.Bundle.Timeout
+	}
+}
+
+// retryPolicy returns the most specific retry policy for a request, with the
+// runner's retry count applied.
+func (r *Runner) retryPolicy(opts Request) *RetryPolicy {
+	policy := opts.Retry
+	if policy == nil {
+		policy = r.Bundle.Retry
+	}
+	if r.Retries > 0 {
+		override := RetryPolicy{}
+		if policy != nil {
+			override = *policy
+		}
+		override.MaxAttempts = r.Retries + 1
+		policy = &override
+	}
+	return policy
+}
+
+// writeRequestHead prints the request line and headers, curl style.
+func writeRequestHead(w io.Writer, req *http.Request) {
+	fmt.Fprintf(w, "> %s %s %s\n", req.Method, req.URL.RequestURI(), req.Proto)
+	fmt.Fprintf(w, "> Host: %s\n", req.URL.Host)
+	for _, key := range sortedHeaderKeys(req.Header) {
+		for _, v := range req.Header[key] {
+			fmt.Fprintf(w, "> %s: %s\n", key, v)
+		}
+	}
+	fmt.Fprintln(w, ">")
+}
+
+// writeResponseHead prints the status line and headers, followed by the blank
+// line that separates them from the body.
+func writeResponseHead(w io.Writer, resp *http.Response) {
+	fmt.Fprintf(w, "%s %s\n", resp.Proto, resp.Status)
+	for _, key := range sortedHeaderKeys(resp.Header) {
+		for _, v := range resp.Header[key] {
+			fmt.Fprintf(w, "%s: %s\n", key, v)
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+// runSavedRequest runs a request and returns the response. The timeout and
+// retry policy of step, when non-nil, take precedence over the request's.
+func (r *Runner) runSavedRequest(ctx context.Context, name string, vars map[string]interface{}, step *ChainStep) (*response, error) {
+	saved, ok := r.Bundle.LookupRequest(name)
+	if !ok {
+		return nil, fmt.Errorf("request '%s' not found in config", name)
+	}
+	if step != nil {
+		if step.Timeout > 0 {
+			saved.Timeout = step.Timeout
+		}
+		if step.Retry != nil {
+			saved.Retry = step.Retry
+		}
+	}
+
+	// Variable Substitution. Environment variables are part of vars already, so
+	// chain variables take precedence over them.
+	opts := saved
+	if vars != nil {
+		opts = substituteRequest(saved, vars)
+	}
+
+	return r.makeRequest(ctx, opts, vars)
+}
+
+// substituteRequest returns a copy of req with variables substituted into the
+// URL, body and headers.
+func substituteRequest(req Request, vars map[string]interface{}) Request {
+	req.URL = substituteURL(req.URL, vars)
+	req.Body = substitute(req.Body, vars)
+	headers := make([]string, len(req.Headers))
+	for i, h := range req.Headers {
+		headers[i] = substitute(h, vars)
+	}
+	req.Headers = headers
+	return req
+}
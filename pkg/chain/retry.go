@@ -0,0 +1,117 @@
+package chain
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultBackoff    = 500 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+)
+
+// defaultRetryStatuses are retried when a policy does not list its own.
+var defaultRetryStatuses = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// RetryPolicy controls how a failed request is retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	MaxAttempts int `mapstructure:"max_attempts"`
+	// Statuses are the response status codes that are retried, by default
+	// 429, 502, 503 and 504.
+	Statuses []int `mapstructure:"statuses"`
+	// NetworkErrors retries transport errors such as timeouts and refused
+	// connections. It defaults to true.
+	NetworkErrors *bool `mapstructure:"network_errors"`
+	// Backoff is the delay before the first retry. It doubles on every
+	// following retry, up to MaxBackoff, with random jitter.
+	Backoff    time.Duration `mapstructure:"backoff"`
+	MaxBackoff time.Duration `mapstructure:"max_backoff"`
+}
+
+func (p *RetryPolicy) attempts() int {
+	if p == nil || p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// retryable reports whether the outcome of an attempt should be retried. Of
+// the errors only transport errors are, since a request that could not be
+// built fails the same way every time.
+func (p *RetryPolicy) retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		var transportErr *transportError
+		if !errors.As(err, &transportErr) {
+			return false
+		}
+		return p.NetworkErrors == nil || *p.NetworkErrors
+	}
+	statuses := p.Statuses
+	if len(statuses) == 0 {
+		statuses = defaultRetryStatuses
+	}
+	for _, status := range statuses {
+		if resp.StatusCode == status {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns how long to wait before the given retry (starting at 1). A
+// Retry-After header on the response takes precedence over the backoff, but
+// is capped at MaxBackoff too.
+func (p *RetryPolicy) delay(retry int, resp *http.Response) time.Duration {
+	maxBackoff := p.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+	if resp != nil {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return min(d, maxBackoff)
+		}
+	}
+
+	backoff := p.Backoff
+	if backoff <= 0 {
+		backoff = defaultBackoff
+	}
+
+	d := backoff
+	for i := 1; i < retry && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	// Jitter between half and the full delay so retries from several
+	// clients spread out.
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as a date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		d := time.Until(at)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
@@ -0,0 +1,236 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryOnStatus(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer ts.Close()
+
+	bundle := &Bundle{
+		BaseURL: ts.URL,
+		Requests: map[string]Request{
+			"flaky": {Method: "GET", URL: "/", Retry: &RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}},
+		},
+	}
+
+	var out, log bytes.Buffer
+	runner := New(bundle)
+	runner.Out = &out
+	runner.Log = &log
+
+	status, err := runner.RunRequest(context.Background(), "flaky")
+	if err != nil {
+		t.Fatalf("RunRequest failed: %v", err)
+	}
+	if status != http.StatusOK || out.String() != "ok" {
+		t.Errorf("expected 200 ok, got %d %q", status, out.String())
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls)
+	}
+	if !strings.Contains(log.String(), "Attempt 1/3 returned 503") {
+		t.Errorf("expected retry to be logged, got %q", log.String())
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	bundle := &Bundle{
+		BaseURL:  ts.URL,
+		Retry:    &RetryPolicy{MaxAttempts: 5, Backoff: time.Millisecond},
+		Requests: map[string]Request{"fail": {Method: "GET", URL: "/"}},
+	}
+
+	runner := New(bundle)
+	runner.Out = &bytes.Buffer{}
+	runner.Log = nil
+
+	// 500 is not retried by default.
+	status, err := runner.RunRequest(context.Background(), "fail")
+	if err != nil {
+		t.Fatalf("RunRequest failed: %v", err)
+	}
+	if status != http.StatusInternalServerError || calls != 1 {
+		t.Errorf("expected a single 500, got %d after %d attempts", status, calls)
+	}
+
+	// The retries flag applies on top of the bundle policy.
+	atomic.StoreInt32(&calls, 0)
+	bundle.Retry.Statuses = []int{http.StatusInternalServerError}
+	runner.Retries = 1
+	if _, err := runner.RunRequest(context.Background(), "fail"); err != nil {
+		t.Fatalf("RunRequest failed: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 attempts, got %d", calls)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer ts.Close()
+
+	bundle := &Bundle{
+		BaseURL:  ts.URL,
+		Requests: map[string]Request{"slow": {Method: "GET", URL: "/"}},
+		Chains: map[string][]ChainStep{
+			"flow": {{
+				Request: "slow",
+				Timeout: 50 * time.Millisecond,
+				Retry:   &RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond},
+			}},
+		},
+	}
+
+	var out bytes.Buffer
+	runner := New(bundle)
+	runner.Out = &out
+	runner.Log = nil
+
+	if err := runner.Run(context.Background(), "flow"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if out.String() != "ok" || calls != 2 {
+		t.Errorf("expected the timed out attempt to be retried, got %q after %d attempts", out.String(), calls)
+	}
+
+	// Without the step's retry policy the timeout is an error.
+	atomic.StoreInt32(&calls, 0)
+	runner.Timeout = 50 * time.Millisecond
+	_, err := runner.RunRequest(context.Background(), "slow")
+	if err == nil || !strings.Contains(err.Error(), "deadline exceeded") {
+		t.Errorf("expected a timeout error, got %v", err)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	policy := &RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}
+
+	tests := []struct {
+		retry    int
+		min, max time.Duration
+	}{
+		{1, 50 * time.Millisecond, 100 * time.Millisecond},
+		{2, 100 * time.Millisecond, 200 * time.Millisecond},
+		{5, 150 * time.Millisecond, 300 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		d := policy.delay(tt.retry, nil)
+		if d < tt.min || d > tt.max {
+			t.Errorf("delay(%d) = %s, expected between %s and %s", tt.retry, d, tt.min, tt.max)
+		}
+	}
+
+	resp := &http.Response{Header: http.Header{"Retry-After": {"2"}}}
+	if d := (&RetryPolicy{}).delay(1, resp); d != 2*time.Second {
+		t.Errorf("expected Retry-After to be honored, got %s", d)
+	}
+	resp.Header.Set("Retry-After", "3600")
+	if d := policy.delay(1, resp); d != 300*time.Millisecond {
+		t.Errorf("expected Retry-After to be capped at MaxBackoff, got %s", d)
+	}
+}
+
+func TestRetryOnlyTransportErrors(t *testing.T) {
+	bundle := &Bundle{
+		BaseURL: "http://127.0.0.1:1",
+		Requests: map[string]Request{
+			"upload": {Method: "POST", URL: "/", Body: "@/does/not/exist", Retry: &RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}},
+		},
+	}
+	var log bytes.Buffer
+	runner := New(bundle)
+	runner.Out = &bytes.Buffer{}
+	runner.Log = &log
+
+	_, err := runner.RunRequest(context.Background(), "upload")
+	if err == nil || !strings.Contains(err.Error(), "failed to open body file") {
+		t.Fatalf("expected the body file error, got %v", err)
+	}
+	if strings.Contains(log.String(), "Attempt") {
+		t.Errorf("expected no retries, got %q", log.String())
+	}
+
+	// A canceled context is not retried either.
+	bundle.Requests["upload"] = Request{Method: "GET", URL: "/", Retry: &RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	log.Reset()
+	if _, err := runner.RunRequest(ctx, "upload"); err == nil {
+		t.Fatal("expected the canceled request to fail")
+	}
+	if strings.Contains(log.String(), "Attempt") {
+		t.Errorf("expected no retries after cancellation, got %q", log.String())
+	}
+}
+
+func TestCurlRetryArgs(t *testing.T) {
+	off := false
+	bundle := &Bundle{
+		BaseURL: "https://api.etin.dev",
+		Timeout: 5 * time.Second,
+		Requests: map[string]Request{
+			"flaky":    {Method: "GET", URL: "/", Retry: &RetryPolicy{MaxAttempts: 3}},
+			"statuses": {Method: "GET", URL: "/", Retry: &RetryPolicy{MaxAttempts: 3, Statuses: []int{500}}},
+			"offline":  {Method: "GET", URL: "/", Retry: &RetryPolicy{MaxAttempts: 3, NetworkErrors: &off}},
+		},
+		Chains: map[string][]ChainStep{
+			"flow": {{Request: "flaky", Retry: &RetryPolicy{MaxAttempts: 2, Statuses: []int{409}}}},
+		},
+	}
+	runner := New(bundle)
+
+	got, err := runner.CurlCommand("flaky")
+	if err != nil {
+		t.Fatalf("CurlCommand failed: %v", err)
+	}
+	if want := "curl --max-time 5 --retry 2 --retry-connrefused 'https://api.etin.dev/'"; !strings.Contains(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	// Policies curl cannot follow are not exported with a different one.
+	for name, want := range map[string]string{
+		"statuses": "request 'statuses': retrying statuses [500] cannot be exported to curl",
+		"offline":  "request 'offline': retrying without network errors cannot be exported to curl",
+	} {
+		if _, err := runner.CurlCommand(name); err == nil || err.Error() != want {
+			t.Errorf("CurlCommand(%q) error = %v, expected %q", name, err, want)
+		}
+	}
+	if _, err := runner.CurlScript("flow"); err == nil || !strings.Contains(err.Error(), "step 'flaky': retrying statuses [409] cannot be exported") {
+		t.Errorf("expected the step's retry policy not to be exported, got %v", err)
+	}
+}
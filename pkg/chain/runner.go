@@ -0,0 +1,200 @@
+// Package chain executes afro requests and chains independently of the CLI.
+package chain
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Runner executes the requests and chains of a bundle.
+type Runner struct {
+	Bundle *Bundle
+	Client *http.Client
+	// Out receives response bodies.
+	Out io.Writer
+	// Log receives progress messages such as the step being run.
+	Log io.Writer
+	// Variables seeds the variables available to every run.
+	Variables map[string]interface{}
+	// Environment selects one of the bundle's environments.
+	Environment string
+	// Include prints the response status line and headers before the body.
+	Include bool
+	// Verbose also prints the outgoing request line and headers to Log.
+	Verbose bool
+	// Format controls how response bodies are written to Out.
+	Format Format
+	// Color highlights pretty-printed JSON with ANSI colors.
+	Color bool
+	// Select is a JSONPath expression; when set only the matching part of
+	// each response is written to Out.
+	Select string
+	// Timeout, when set, overrides the timeout of every request.
+	Timeout time.Duration
+	// Retries, when set, overrides how many times failed requests are retried.
+	Retries int
+	// Strict fails a step when an extraction misses or a {{placeholder}} is
+	// left unresolved, rather than warning and sending the request as is.
+	Strict bool
+	// Report, when set, collects the outcome of every step of a run.
+	Report *Report
+	// SnapshotDir is where response snapshots are stored, __snapshots__ in
+	// the bundle's directory by default.
+	SnapshotDir string
+	// UpdateSnapshots saves the live responses as snapshots instead of
+	// comparing them, so missing snapshots are created. Without it a missing
+	// snapshot fails the step.
+	UpdateSnapshots bool
+
+	// callStack holds the chains being run, outermost first.
+	callStack []string
+	// position holds the iteration or branch index of each loop and
+	// parallel group being run, outermost first.
+	position  []int
+	snapshots *snapshotNames
+	failures  *failureList
+	schemas   *schemaCache
+	// tools collects the commands an exported script needs.
+	tools curlTools
+}
+
+// New returns a Runner for the given bundle writing to stdout and stderr.
+func New(bundle *Bundle) *Runner {
+	if bundle == nil {
+		bundle = &Bundle{}
+	}
+	return &Runner{
+		Bundle:    bundle,
+		Client:    &http.Client{},
+		Out:       os.Stdout,
+		Log:       os.Stderr,
+		Variables: make(map[string]interface{}),
+		schemas:   &schemaCache{},
+	}
+}
+
+// Run executes the named chain.
+func (r *Runner) Run(ctx context.Context, name string) (err error) {
+	if r.Report != nil {
+		end := r.Report.begin(name)
+		defer func() { end(err) }()
+	}
+
+	run, steps, err := r.enterChain(name)
+	if err != nil {
+		return err
+	}
+
+	variables, err := r.initialVariables()
+	if err != nil {
+		return err
+	}
+
+	run.snapshots = &snapshotNames{}
+	run.failures = &failureList{}
+	// Failures the chain continued after are reported once it finishes.
+	if err := run.failures.err(run.executeSteps(ctx, steps, variables)); err != nil {
+		return fmt.Errorf("chain execution failed: %w", err)
+	}
+	return nil
+}
+
+// RunRequest executes the named saved request and returns the status code.
+func (r *Runner) RunRequest(ctx context.Context, name string) (status int, err error) {
+	if r.Report != nil {
+		end := r.Report.begin(name)
+		defer func() { end(err) }()
+	}
+
+	variables, err := r.initialVariables()
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	resp, err := r.runSavedRequest(ctx, name, variables, nil)
+	r.record(ChainStep{Request: name}, start, resp, nil, err)
+	return resp.status(), err
+}
+
+// Do executes an ad hoc request and returns the status code.
+func (r *Runner) Do(ctx context.Context, req Request) (int, error) {
+	resp, err := r.makeRequest(ctx, req, nil)
+	return resp.status(), err
+}
+
+// environment returns the selected environment, or an empty one if none is selected.
+func (r *Runner) environment() (Environment, error) {
+	if r.Environment == "" {
+		return Environment{}, nil
+	}
+	env, ok := r.Bundle.Environments[r.Environment]
+	if !ok {
+		return Environment{}, fmt.Errorf("environment '%s' not found in config", r.Environment)
+	}
+	return env, nil
+}
+
+// configVariables returns the variables defined by the bundle and the selected
+// environment, or nil if there are none.
+func (r *Runner) configVariables() (map[string]interface{}, error) {
+	env, err := r.environment()
+	if err != nil {
+		return nil, err
+	}
+	if len(r.Bundle.Variables) == 0 && len(env.Variables) == 0 {
+		return nil, nil
+	}
+
+	variables := make(map[string]interface{}, len(r.Bundle.Variables)+len(env.Variables))
+	for k, v := range r.Bundle.Variables {
+		variables[k] = v
+	}
+	for k, v := range env.Variables {
+		variables[k] = v
+	}
+	return variables, nil
+}
+
+// initialVariables returns the variables a run starts with. Bundle and
+// environment variables are resolved first so that runner variables can
+// override them.
+func (r *Runner) initialVariables() (map[string]interface{}, error) {
+	configVars, err := r.configVariables()
+	if err != nil {
+		return nil, err
+	}
+
+	variables := make(map[string]interface{}, len(configVars)+len(r.Variables))
+	for k, v := range configVars {
+		variables[k] = v
+	}
+	for k, v := range r.Variables {
+		variables[k] = v
+	}
+	return variables, nil
+}
+
+func (r *Runner) out() io.Writer {
+	if r.Out == nil {
+		return os.Stdout
+	}
+	return r.Out
+}
+
+func (r *Runner) log() io.Writer {
+	if r.Log == nil {
+		return io.Discard
+	}
+	return r.Log
+}
+
+func (r *Runner) client() *http.Client {
+	if r.Client == nil {
+		return http.DefaultClient
+	}
+	return r.Client
+}
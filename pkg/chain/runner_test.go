@@ -0,0 +1,166 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRunnerRun(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			fmt.Fprint(w, `{"token": "secret-token"}`)
+		case "/me":
+			if r.Header.Get("Authorization") != "Bearer secret-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprintf(w, `{"name": "%s"}`, r.URL.Query().Get("as"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	bundle := &Bundle{
+		BaseURL: ts.URL,
+		Requests: map[string]Request{
+			"login": {Method: "POST", URL: "/login"},
+			"me": {
+				Method:  "GET",
+				URL:     "/me?as={{user}}",
+				Headers: []string{"Authorization: Bearer {{token}}"},
+			},
+		},
+		Chains: map[string][]ChainStep{
+			"flow": {
+				{Request: "login", Extract: map[string]string{"token": "$.token"}},
+				{
+					Request: "me",
+					Extract: map[string]string{"name": "$.name"},
+					Assert:  []Assertion{{Left: "{{name}}", Op: "==", Right: "ada"}},
+				},
+			},
+		},
+	}
+
+	var out, log bytes.Buffer
+	runner := New(bundle)
+	runner.Client = ts.Client()
+	runner.Out = &out
+	runner.Log = &log
+	runner.Variables["user"] = "ada"
+
+	if err := runner.Run(context.Background(), "flow"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if got := out.String(); got != `{"token": "secret-token"}{"name": "ada"}` {
+		t.Errorf("unexpected output %q", got)
+	}
+	if got := log.String(); got != "Running step: login\nRunning step: me\n" {
+		t.Errorf("unexpected log %q", got)
+	}
+	if _, ok := runner.Variables["token"]; ok {
+		t.Errorf("extracted variables leaked into runner variables")
+	}
+}
+
+func TestRunnerBundleHeaders(t *testing.T) {
+	var got []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("Authorization")+"|"+r.Header.Get("X-Tenant"))
+		fmt.Fprint(w, `{"token": "secret-token"}`)
+	}))
+	defer ts.Close()
+
+	bundle := &Bundle{
+		BaseURL:   ts.URL,
+		Headers:   map[string]string{"Authorization": "Bearer {{token}}"},
+		Variables: map[string]string{"token": "none"},
+		Environments: map[string]Environment{
+			"dev": {Headers: map[string]string{"X-Tenant": "{{tenant}}"}},
+		},
+		Requests: map[string]Request{
+			"login": {Method: "POST", URL: "/login", NoHeaders: true},
+			"me":    {Method: "GET", URL: "/me"},
+		},
+		Chains: map[string][]ChainStep{
+			"flow": {
+				{Request: "login", Extract: map[string]string{"token": "$.token", "tenant": "$.token"}},
+				{Request: "me"},
+			},
+		},
+	}
+	runner := New(bundle)
+	runner.Out = &bytes.Buffer{}
+	runner.Log = nil
+	runner.Strict = true
+	runner.Environment = "dev"
+
+	// Bundle and environment headers use the chain's variables like the
+	// request's own headers do.
+	if err := runner.Run(context.Background(), "flow"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if want := "Bearer secret-token|secret-token"; len(got) != 2 || got[1] != want {
+		t.Errorf("expected headers %q, got %v", want, got)
+	}
+}
+
+func TestRunnerUnknownChain(t *testing.T) {
+	runner := New(&Bundle{})
+	if err := runner.Run(context.Background(), "missing"); err == nil {
+		t.Errorf("expected error for unknown chain")
+	}
+}
+
+func TestRunnerIncludeAndVerbose(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", "abc")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id": 7}`)
+	}))
+	defer ts.Close()
+
+	bundle := &Bundle{
+		BaseURL: ts.URL,
+		Requests: map[string]Request{
+			"create": {Method: "POST", URL: "/items?x=1", Headers: []string{"X-Trace: t1"}},
+		},
+		Chains: map[string][]ChainStep{
+			"flow": {{
+				Request: "create",
+				Extract: map[string]string{"id": "$.id"},
+				Assert:  []Assertion{{Left: "{{id}}", Op: "==", Right: "7"}},
+			}},
+		},
+	}
+
+	var out, log bytes.Buffer
+	runner := New(bundle)
+	runner.Out = &out
+	runner.Log = &log
+	runner.Verbose = true
+
+	// Extraction must still see only the body.
+	if err := runner.Run(context.Background(), "flow"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if !strings.HasPrefix(out.String(), "HTTP/1.1 201 Created\nContent-Length: 9\nContent-Type: application/json\n") {
+		t.Errorf("expected status line and headers first, got %q", out.String())
+	}
+	if !strings.Contains(out.String(), "X-Request-Id: abc\n\n{\"id\": 7}") {
+		t.Errorf("expected headers followed by body, got %q", out.String())
+	}
+	if !strings.Contains(log.String(), "> POST /items?x=1 HTTP/1.1\n") || !strings.Contains(log.String(), "> X-Trace: t1\n") {
+		t.Errorf("expected request line and headers in log, got %q", log.String())
+	}
+}
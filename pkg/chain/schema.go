@@ -0,0 +1,242 @@
+package chain
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"go.yaml.in/yaml/v3"
+)
+
+// inlineSchemaURL identifies schemas written inline in a step.
+const inlineSchemaURL = "file:///afro/inline-schema.json"
+
+// schemaCache holds the schemas a runner compiled, by their reference, so
+// polls and loops compile each only once.
+type schemaCache struct {
+	mu      sync.Mutex
+	schemas map[string]*jsonschema.Schema
+}
+
+// schema returns the compiled schema for a step's reference.
+func (r *Runner) schema(ref string) (*jsonschema.Schema, error) {
+	if r.schemas == nil {
+		return r.compileSchema(ref)
+	}
+	r.schemas.mu.Lock()
+	defer r.schemas.mu.Unlock()
+	if schema, ok := r.schemas.schemas[ref]; ok {
+		return schema, nil
+	}
+	schema, err := r.compileSchema(ref)
+	if err != nil {
+		return nil, err
+	}
+	if r.schemas.schemas == nil {
+		r.schemas.schemas = make(map[string]*jsonschema.Schema)
+	}
+	r.schemas.schemas[ref] = schema
+	return schema, nil
+}
+
+// validateSchema validates the JSON body of a response against the step's
+// schema, returning a *schemaError listing the violations.
+func (r *Runner) validateSchema(step ChainStep, resp *response) error {
+	schema, err := r.schema(step.Schema)
+	if err != nil {
+		return fmt.Errorf("invalid schema '%s': %w", schemaLabel(step.Schema), err)
+	}
+
+	body, err := jsonschema.UnmarshalJSON(bytes.NewReader(resp.Body))
+	if err != nil {
+		return fmt.Errorf("failed to parse response as JSON: %w", err)
+	}
+
+	err = schema.Validate(body)
+	var validationErr *jsonschema.ValidationError
+	if errors.As(err, &validationErr) {
+		return &schemaError{violations: violations(validationErr)}
+	}
+	return err
+}
+
+// compileSchema compiles a schema given inline as YAML or JSON, or referenced
+// as a file with an optional #/json/pointer. A reference that is only a
+// pointer, such as #/components/schemas/User, points into the bundle's
+// OpenAPI document. Files are resolved against the bundle's directory.
+func (r *Runner) compileSchema(ref string) (*jsonschema.Schema, error) {
+	c := jsonschema.NewCompiler()
+	c.DefaultDraft(jsonschema.Draft2020)
+
+	if isInlineSchema(ref) {
+		doc, err := decodeSchema([]byte(ref))
+		if err != nil {
+			return nil, err
+		}
+		return compileInlineSchema(c, doc)
+	}
+
+	path, pointer, _ := strings.Cut(strings.TrimSpace(ref), "#")
+	if path == "" {
+		if r.Bundle.OpenAPI == "" {
+			return nil, fmt.Errorf("the bundle has no 'openapi' document to resolve it in")
+		}
+		path = r.Bundle.OpenAPI
+	}
+	path = r.Bundle.path(path)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		// A one-line schema such as "type: object" is not a file.
+		if doc, decodeErr := decodeSchema([]byte(ref)); decodeErr == nil {
+			if _, ok := doc.(map[string]interface{}); ok {
+				return compileInlineSchema(c, doc)
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	doc, err := decodeSchema(data)
+	if err != nil {
+		return nil, err
+	}
+	if isOpenAPI30(doc) {
+		doc = translateOpenAPI30(doc)
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	url := "file://" + filepath.ToSlash(abs)
+	if err := c.AddResource(url, doc); err != nil {
+		return nil, err
+	}
+	return c.Compile(url + "#" + pointer)
+}
+
+func compileInlineSchema(c *jsonschema.Compiler, doc interface{}) (*jsonschema.Schema, error) {
+	if err := c.AddResource(inlineSchemaURL, doc); err != nil {
+		return nil, err
+	}
+	return c.Compile(inlineSchemaURL)
+}
+
+// isInlineSchema reports whether a step's schema is written out rather than
+// referenced. Written out schemas are JSON objects or span several lines; a
+// one-line YAML schema is only told apart from a path by the file not
+// existing, so compileSchema falls back to it.
+func isInlineSchema(ref string) bool {
+	ref = strings.TrimSpace(ref)
+	return strings.HasPrefix(ref, "{") || strings.Contains(ref, "\n")
+}
+
+func schemaLabel(ref string) string {
+	if isInlineSchema(ref) {
+		return "inline"
+	}
+	return ref
+}
+
+// decodeSchema parses a YAML or JSON document into the form the validator
+// expects.
+func decodeSchema(data []byte) (interface{}, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse schema: %w", err)
+	}
+	converted, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema: %w", err)
+	}
+	return jsonschema.UnmarshalJSON(bytes.NewReader(converted))
+}
+
+// isOpenAPI30 reports whether doc is an OpenAPI 3.0 document, whose schemas
+// are not quite JSON Schema.
+func isOpenAPI30(doc interface{}) bool {
+	m, ok := doc.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	version, _ := m["openapi"].(string)
+	return strings.HasPrefix(version, "3.0")
+}
+
+// translateOpenAPI30 rewrites the OpenAPI 3.0 keywords that differ from JSON
+// Schema 2020-12: nullable: true, which allows null, and the boolean
+// exclusiveMinimum and exclusiveMaximum, which modify minimum and maximum.
+func translateOpenAPI30(node interface{}) interface{} {
+	switch v := node.(type) {
+	case []interface{}:
+		for i, item := range v {
+			v[i] = translateOpenAPI30(item)
+		}
+		return v
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = translateOpenAPI30(item)
+		}
+		for keyword, bound := range map[string]string{"exclusiveMinimum": "minimum", "exclusiveMaximum": "maximum"} {
+			if exclusive, ok := v[keyword].(bool); ok {
+				delete(v, keyword)
+				if limit, ok := v[bound]; ok && exclusive {
+					v[keyword] = limit
+					delete(v, bound)
+				}
+			}
+		}
+		if nullable, ok := v["nullable"].(bool); ok {
+			delete(v, "nullable")
+			if nullable {
+				return nullableSchema(v)
+			}
+		}
+		return v
+	default:
+		return node
+	}
+}
+
+// nullableSchema returns a schema that also allows null.
+func nullableSchema(schema map[string]interface{}) interface{} {
+	if t, ok := schema["type"].(string); ok {
+		schema["type"] = []interface{}{t, "null"}
+		if enum, ok := schema["enum"].([]interface{}); ok {
+			schema["enum"] = append(enum, nil)
+		}
+		return schema
+	}
+	return map[string]interface{}{
+		"anyOf": []interface{}{schema, map[string]interface{}{"type": "null"}},
+	}
+}
+
+// violations flattens a validation error into its innermost causes, each
+// naming the path of the offending value.
+func violations(err *jsonschema.ValidationError) []string {
+	if len(err.Causes) == 0 {
+		return []string{err.Error()}
+	}
+	var out []string
+	for _, cause := range err.Causes {
+		out = append(out, violations(cause)...)
+	}
+	return out
+}
+
+// schemaError lists the ways a response violates its schema.
+type schemaError struct {
+	violations []string
+}
+
+func (e *schemaError) Error() string {
+	return "response does not match schema: " + strings.Join(e.violations, "; ")
+}
@@ -0,0 +1,200 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const userSchema = `
+type: object
+required: [id, email]
+properties:
+  id:
+    type: integer
+  email:
+    type: string
+`
+
+func TestSchemaValidation(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/good":
+			fmt.Fprint(w, `{"id":1,"email":"ada@etin.dev","roles":["admin"]}`)
+		case "/bad":
+			fmt.Fprint(w, `{"id":"1","roles":[1]}`)
+		}
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	spec := filepath.Join(dir, "openapi.yaml")
+	err := os.WriteFile(spec, []byte(`openapi: 3.1.0
+components:
+  schemas:
+    Role:
+      type: string
+    User:
+      type: object
+      required: [id]
+      properties:
+        id:
+          type: integer
+        roles:
+          type: array
+          items:
+            $ref: '#/components/schemas/Role'
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userFile := filepath.Join(dir, "user.json")
+	if err := os.WriteFile(userFile, []byte(`{"type":"object","required":["email"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	bundle := &Bundle{
+		BaseURL: ts.URL,
+		OpenAPI: spec,
+		Requests: map[string]Request{
+			"good": {Method: "GET", URL: "/good"},
+			"bad":  {Method: "GET", URL: "/bad"},
+		},
+	}
+	runner := New(bundle)
+	runner.Out = &bytes.Buffer{}
+	runner.Log = nil
+
+	tests := []struct {
+		request, schema string
+		want            []string
+	}{
+		{"good", userSchema, nil},
+		{"good", `{"type":"object"}`, nil},
+		{"good", "#/components/schemas/User", nil},
+		{"good", userFile, nil},
+		{"good", spec + "#/components/schemas/User", nil},
+		{"bad", userSchema, []string{"at '': missing property 'email'", "at '/id': got string, want integer"}},
+		{"bad", "#/components/schemas/User", []string{"at '/id'", "at '/roles/0': got number, want string"}},
+		{"good", "#/components/schemas/Missing", []string{"invalid schema '#/components/schemas/Missing'"}},
+		// A one-line YAML schema is not mistaken for a file.
+		{"good", "type: object", nil},
+		{"good", "type: array", []string{"at '': got object, want array"}},
+		{"good", "missing.yaml", []string{"invalid schema 'missing.yaml'", "no such file"}},
+	}
+	for _, tt := range tests {
+		bundle.Chains = map[string][]ChainStep{"flow": {{Request: tt.request, Schema: tt.schema}}}
+		err := runner.Run(context.Background(), "flow")
+		if len(tt.want) == 0 {
+			if err != nil {
+				t.Errorf("%s against %q: unexpected error %v", tt.request, tt.schema, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s against %q: expected an error", tt.request, tt.schema)
+			continue
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s against %q: expected %q in %v", tt.request, tt.schema, want, err)
+			}
+		}
+	}
+}
+
+func TestSchemaOpenAPI30(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":1,"nickname":null,"status":null,"score":0}`)
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "openapi.yaml"), []byte(`openapi: 3.0.3
+components:
+  schemas:
+    Status:
+      type: string
+      enum: [active]
+    User:
+      type: object
+      properties:
+        id:
+          type: integer
+        nickname:
+          type: string
+          nullable: true
+        status:
+          allOf:
+            - $ref: '#/components/schemas/Status'
+          nullable: true
+        score:
+          type: number
+          minimum: 0
+          exclusiveMinimum: true
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "schemas"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "schemas", "user.json"), []byte(`{"required":["id"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Paths are relative to the bundle, not the working directory.
+	bundle := &Bundle{
+		BaseURL:  ts.URL,
+		OpenAPI:  "openapi.yaml",
+		Dir:      dir,
+		Requests: map[string]Request{"user": {Method: "GET", URL: "/user"}},
+		Chains: map[string][]ChainStep{
+			"flow": {
+				{Request: "user", Schema: "schemas/user.json"},
+				{Request: "user", Schema: "#/components/schemas/User"},
+			},
+		},
+	}
+	runner := New(bundle)
+	runner.Out = &bytes.Buffer{}
+	runner.Log = nil
+
+	err = runner.Run(context.Background(), "flow")
+	if err == nil {
+		t.Fatal("expected the exclusive minimum to fail")
+	}
+	if !strings.Contains(err.Error(), "at '/score'") || strings.Contains(err.Error(), "nickname") || strings.Contains(err.Error(), "status") {
+		t.Errorf("expected only the score to fail, got %v", err)
+	}
+
+	// Compiled schemas are kept for the runner's later steps and runs.
+	bundle.Chains["flow"] = bundle.Chains["flow"][:1]
+	if err := os.Remove(filepath.Join(dir, "schemas", "user.json")); err != nil {
+		t.Fatal(err)
+	}
+	if err := runner.Run(context.Background(), "flow"); err != nil {
+		t.Errorf("expected the cached schema to be used, got %v", err)
+	}
+}
+
+func TestCurlScriptSchema(t *testing.T) {
+	bundle := &Bundle{
+		BaseURL:  "https://api.etin.dev",
+		Requests: map[string]Request{"user": {Method: "GET", URL: "/user"}},
+		Chains: map[string][]ChainStep{
+			"flow": {{Request: "user", Schema: `{"type": "object"}`}},
+		},
+	}
+
+	_, err := New(bundle).CurlScript("flow")
+	if err == nil || !strings.Contains(err.Error(), "the response schema check of step 'user' cannot be exported") {
+		t.Errorf("expected the schema check not to be exported, got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package chain
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+
+	"github.com/oliveagle/jsonpath"
+)
+
+// multiMatchPattern matches JSONPath brackets that can select several values:
+// wildcards, filters, slices and unions.
+var multiMatchPattern = regexp.MustCompile(`\[[^\]]*[*?:,][^\]]*\]|\.\*`)
+
+// renderSelection writes the part of a JSON body selected by path. Scalars are
+// written unquoted so they can be used in shell scripts, and expressions that
+// can match several values write one result per line.
+func (r *Runner) renderSelection(w io.Writer, body []byte, path string) error {
+	var data interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return &selectionError{fmt.Errorf("cannot select from response: %w", err)}
+	}
+	res, err := jsonpath.JsonPathLookup(data, path)
+	if err != nil {
+		return &selectionError{fmt.Errorf("failed to select '%s': %w", path, err)}
+	}
+
+	if results, ok := res.([]interface{}); ok && multiMatchPattern.MatchString(path) {
+		for i, item := range results {
+			if i > 0 {
+				if _, err := io.WriteString(w, "\n"); err != nil {
+					return err
+				}
+			}
+			if err := r.renderValue(w, item, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return r.renderValue(w, res, true)
+}
+
+// renderValue writes a scalar unquoted, and anything else as JSON rendered in
+// the runner's format, or compact when pretty is false.
+func (r *Runner) renderValue(w io.Writer, v interface{}, pretty bool) error {
+	var s string
+	switch val := v.(type) {
+	case string:
+		s = val
+	case float64:
+		s = strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		s = strconv.FormatBool(val)
+	case nil:
+		s = "null"
+	default:
+		encoded, err := json.Marshal(val)
+		if err != nil {
+			return err
+		}
+		if pretty {
+			return r.render(w, encoded, "application/json")
+		}
+		_, err = w.Write(encoded)
+		return err
+	}
+	_, err := io.WriteString(w, s)
+	return err
+}
+
+// selectionError is a response that --select does not apply to, as opposed to
+// a failure to write the selection.
+type selectionError struct {
+	err error
+}
+
+func (e *selectionError) Error() string { return e.err.Error() }
+func (e *selectionError) Unwrap() error { return e.err }
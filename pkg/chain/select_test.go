@@ -0,0 +1,84 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRenderSelection(t *testing.T) {
+	body := []byte(`{"data": [{"id": 1, "name": "a"}, {"id": 2.5, "name": "b"}], "total": 2, "next": null, "owner": {"name": "ada"}}`)
+
+	tests := map[string]string{
+		"$.total":        "2",
+		"$.data[0].name": "a",
+		"$.data[*].id":   "1\n2.5",
+		"$.data[0:1]":    "{\"id\":1,\"name\":\"a\"}\n{\"id\":2.5,\"name\":\"b\"}",
+		"$.next":         "null",
+		"$.owner":        `{"name":"ada"}`,
+		"$.data[1].name": "b",
+		"$.owner.name":   "ada",
+	}
+
+	for path, expected := range tests {
+		var out bytes.Buffer
+		if err := (&Runner{}).renderSelection(&out, body, path); err != nil {
+			t.Errorf("renderSelection(%q) failed: %v", path, err)
+			continue
+		}
+		if out.String() != expected {
+			t.Errorf("renderSelection(%q) = %q, expected %q", path, out.String(), expected)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := (&Runner{}).renderSelection(&out, []byte("not json"), "$.id"); err == nil {
+		t.Errorf("expected error selecting from a non-JSON body")
+	}
+}
+
+func TestSelectDoesNotFailChain(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		fmt.Fprint(w, `{"id":7}`)
+	}))
+	defer ts.Close()
+
+	bundle := &Bundle{
+		BaseURL: ts.URL,
+		Requests: map[string]Request{
+			"login": {Method: "POST", URL: "/login"},
+			"me":    {Method: "GET", URL: "/me"},
+		},
+		Chains: map[string][]ChainStep{
+			"flow": {{Request: "login"}, {Request: "me", Extract: map[string]string{"id": "$.id"}}},
+		},
+	}
+	var out, log bytes.Buffer
+	runner := New(bundle)
+	runner.Out = &out
+	runner.Log = &log
+	runner.Select = "$.id"
+
+	if err := runner.Run(context.Background(), "flow"); err != nil {
+		t.Fatalf("expected the chain to run despite --select, got %v", err)
+	}
+	if out.String() != "7" {
+		t.Errorf("expected the selection of the second step, got %q", out.String())
+	}
+	if !strings.Contains(log.String(), "Warning: cannot select from response") {
+		t.Errorf("expected a warning for the first step, got %q", log.String())
+	}
+
+	// A single request still fails, since there is nothing else to show.
+	if _, err := runner.RunRequest(context.Background(), "login"); err == nil {
+		t.Error("expected selecting from an empty body to fail a single request")
+	}
+}
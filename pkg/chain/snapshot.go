@@ -0,0 +1,336 @@
+package chain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultSnapshotDir is used in the bundle's directory when the runner has no
+// SnapshotDir.
+const defaultSnapshotDir = "__snapshots__"
+
+// ignoredValue replaces the values of ignored paths in snapshots.
+const ignoredValue = "<ignored>"
+
+// Snapshot compares a step's response body with one stored by an earlier run.
+type Snapshot struct {
+	// Name names the stored file, by default after the chain and request.
+	Name string `mapstructure:"name"`
+	// Ignore lists JSONPaths of volatile values, such as ids and timestamps,
+	// that are left out of the comparison.
+	Ignore []string `mapstructure:"ignore"`
+}
+
+// snapshotNames numbers the snapshots of a run that would otherwise share a
+// name, such as those of a request run twice in a chain.
+type snapshotNames struct {
+	mu   sync.Mutex
+	seen map[string]int
+}
+
+func (n *snapshotNames) next(name string) string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.seen == nil {
+		n.seen = make(map[string]int)
+	}
+	n.seen[name]++
+	if count := n.seen[name]; count > 1 {
+		return name + "-" + strconv.Itoa(count)
+	}
+	return name
+}
+
+var snapshotNameReplacer = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// checkSnapshot compares the normalized response body with the step's stored
+// snapshot, or stores it when updating snapshots. A missing snapshot fails the
+// step, so a run without its snapshots cannot pass.
+func (r *Runner) checkSnapshot(step ChainStep, resp *response) error {
+	dir := r.SnapshotDir
+	if dir == "" {
+		dir = r.Bundle.path(defaultSnapshotDir)
+	}
+	path := filepath.Join(dir, r.snapshotName(step)+".snap")
+
+	actual, err := normalizeSnapshot(resp.Body, step.Snapshot.Ignore)
+	if err != nil {
+		return err
+	}
+
+	if r.UpdateSnapshots {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to save snapshot: %w", err)
+		}
+		if err := os.WriteFile(path, actual, 0644); err != nil {
+			return fmt.Errorf("failed to save snapshot: %w", err)
+		}
+		fmt.Fprintf(r.log(), "Saved snapshot %s\n", path)
+		return nil
+	}
+
+	stored, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("snapshot %s does not exist (run with --update-snapshots to save it)", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	if !bytes.Equal(stored, actual) {
+		return fmt.Errorf("response does not match snapshot %s (run with --update-snapshots to accept it):\n%s", path, lineDiff(string(stored), string(actual)))
+	}
+	return nil
+}
+
+// snapshotName names a step's snapshot after its chain and request, or its
+// own name. Inside loops and parallel groups the iteration or branch, counted
+// from 1, is appended, so the name does not depend on the order steps finish
+// in.
+func (r *Runner) snapshotName(step ChainStep) string {
+	name := step.Snapshot.Name
+	if name == "" {
+		name = step.Request
+		if chain := r.chainName(); chain != "" {
+			name = chain + "_" + name
+		}
+	}
+	name = snapshotNameReplacer.ReplaceAllString(name, "_")
+	for _, i := range r.position {
+		name += "_" + strconv.Itoa(i+1)
+	}
+	if r.snapshots != nil {
+		name = r.snapshots.next(name)
+	}
+	return name
+}
+
+// at returns a copy of the runner for running the i-th iteration of a loop or
+// branch of a parallel group.
+func (r *Runner) at(i int) *Runner {
+	sub := *r
+	sub.position = append(append([]int{}, r.position...), i)
+	return &sub
+}
+
+// normalizeSnapshot renders a JSON body with sorted keys and indentation, and
+// with the values of ignored paths replaced. Other bodies are kept as they are.
+func normalizeSnapshot(body []byte, ignore []string) ([]byte, error) {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		if len(ignore) > 0 {
+			return nil, fmt.Errorf("cannot ignore paths in a response that is not JSON: %w", err)
+		}
+		return body, nil
+	}
+
+	for _, path := range ignore {
+		steps, err := parseMaskPath(path)
+		if err != nil {
+			return nil, err
+		}
+		doc = maskPath(doc, steps)
+	}
+
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// maskStep is one step of an ignored path: a key, an index, or a wildcard
+// matching every key or index. Recursive steps also match at any depth.
+type maskStep struct {
+	key       string
+	index     int
+	isIndex   bool
+	wildcard  bool
+	recursive bool
+}
+
+// parseMaskPath parses the JSONPath subset used to ignore values: $.key,
+// $['key'], $[0], $[*], $.* and $..key.
+func parseMaskPath(path string) ([]maskStep, error) {
+	p := strings.TrimSpace(path)
+	if !strings.HasPrefix(p, "$") {
+		return nil, fmt.Errorf("invalid ignore path '%s', expected it to start with '$'", path)
+	}
+	p = p[1:]
+
+	var steps []maskStep
+	for len(p) > 0 {
+		var step maskStep
+		switch {
+		case strings.HasPrefix(p, ".."):
+			step.recursive = true
+			p = p[2:]
+			fallthrough
+		case p[0] == '.':
+			p = strings.TrimPrefix(p, ".")
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			step.key, p = p[:end], p[end:]
+			if step.key == "" {
+				return nil, fmt.Errorf("invalid ignore path '%s'", path)
+			}
+			step.wildcard = step.key == "*"
+		case p[0] == '[':
+			end := strings.Index(p, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("missing ']' in ignore path '%s'", path)
+			}
+			inner := strings.TrimSpace(p[1:end])
+			p = p[end+1:]
+			switch {
+			case inner == "*":
+				step.wildcard = true
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				step.key = inner[1 : len(inner)-1]
+			default:
+				n, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("unsupported '[%s]' in ignore path '%s'", inner, path)
+				}
+				step.index, step.isIndex = n, true
+			}
+		default:
+			return nil, fmt.Errorf("invalid ignore path '%s'", path)
+		}
+		steps = append(steps, step)
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("ignore path '%s' would ignore the whole response", path)
+	}
+	return steps, nil
+}
+
+// maskPath returns v with the values matched by steps replaced.
+func maskPath(v interface{}, steps []maskStep) interface{} {
+	if len(steps) == 0 {
+		return ignoredValue
+	}
+	step, rest := steps[0], steps[1:]
+
+	switch node := v.(type) {
+	case map[string]interface{}:
+		for k, child := range node {
+			if step.wildcard || (!step.isIndex && k == step.key) {
+				node[k] = maskPath(child, rest)
+			} else if step.recursive {
+				node[k] = maskPath(child, steps)
+			}
+		}
+	case []interface{}:
+		for i, child := range node {
+			index := step.index
+			if index < 0 {
+				index += len(node)
+			}
+			if step.wildcard || (step.isIndex && i == index) {
+				node[i] = maskPath(child, rest)
+			} else if step.recursive {
+				node[i] = maskPath(child, steps)
+			}
+		}
+	}
+	return v
+}
+
+// diffContext is the number of unchanged lines shown around changes.
+const diffContext = 2
+
+// maxDiffCells limits the size of the table lineDiff compares the changed
+// lines with, about 8 MB.
+const maxDiffCells = 1 << 20
+
+// lineDiff describes how the lines of b differ from a, prefixing removed
+// lines with '-' and added ones with '+', with a few unchanged lines around
+// each change. When too many lines changed to compare them, it only says so.
+func lineDiff(a, b string) string {
+	x := strings.Split(strings.TrimSuffix(a, "\n"), "\n")
+	y := strings.Split(strings.TrimSuffix(b, "\n"), "\n")
+
+	// Lines that did not change at the start and end are set aside, which
+	// leaves little to compare when only a few values changed.
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+	mx, my := x[prefix:len(x)-suffix], y[prefix:len(y)-suffix]
+	if (len(mx)+1)*(len(my)+1) > maxDiffCells {
+		return fmt.Sprintf("  (%d lines differ, too many to compare)", max(len(mx), len(my)))
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of mx[i:] and my[j:].
+	lcs := make([][]int, len(mx)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(my)+1)
+	}
+	for i := len(mx) - 1; i >= 0; i-- {
+		for j := len(my) - 1; j >= 0; j-- {
+			if mx[i] == my[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	lines := make([]string, 0, len(x)+len(my))
+	for _, line := range x[:prefix] {
+		lines = append(lines, "  "+line)
+	}
+	i, j := 0, 0
+	for i < len(mx) || j < len(my) {
+		switch {
+		case i < len(mx) && j < len(my) && mx[i] == my[j]:
+			lines = append(lines, "  "+mx[i])
+			i++
+			j++
+		case i < len(mx) && (j == len(my) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "- "+mx[i])
+			i++
+		default:
+			lines = append(lines, "+ "+my[j])
+			j++
+		}
+	}
+	for _, line := range x[len(x)-suffix:] {
+		lines = append(lines, "  "+line)
+	}
+
+	// Keep the changed lines and their context, marking the gaps.
+	keep := make([]bool, len(lines))
+	for n, line := range lines {
+		if line[0] != ' ' {
+			for k := max(0, n-diffContext); k <= min(len(lines)-1, n+diffContext); k++ {
+				keep[k] = true
+			}
+		}
+	}
+	var out []string
+	for n, line := range lines {
+		if keep[n] {
+			out = append(out, line)
+		} else if n == 0 || keep[n-1] {
+			out = append(out, "  ...")
+		}
+	}
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,205 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	name := "ada"
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprintf(w, `{"name":"%s","id":%d,"meta":{"created_at":"2026-01-%02d"},"items":[{"id":%d,"n":1}]}`,
+			name, calls, calls, calls)
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	bundle := &Bundle{
+		BaseURL:  ts.URL,
+		Requests: map[string]Request{"user": {Method: "GET", URL: "/user"}},
+		Chains: map[string][]ChainStep{
+			"flow": {{
+				Request:  "user",
+				Snapshot: &Snapshot{Ignore: []string{"$.id", "$.meta.created_at", "$.items[*].id"}},
+			}},
+		},
+	}
+	runner := New(bundle)
+	runner.Out = &bytes.Buffer{}
+	runner.Log = nil
+	runner.SnapshotDir = dir
+
+	// A missing snapshot fails the run until it is saved.
+	err := runner.Run(context.Background(), "flow")
+	if err == nil || !strings.Contains(err.Error(), "flow_user.snap does not exist (run with --update-snapshots to save it)") {
+		t.Fatalf("expected a missing snapshot to fail, got %v", err)
+	}
+	runner.UpdateSnapshots = true
+	if err := runner.Run(context.Background(), "flow"); err != nil {
+		t.Fatalf("saving run failed: %v", err)
+	}
+	runner.UpdateSnapshots = false
+	path := filepath.Join(dir, "flow_user.snap")
+	stored, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected a snapshot to be saved: %v", err)
+	}
+	want := `{
+  "id": "<ignored>",
+  "items": [
+    {
+      "id": "<ignored>",
+      "n": 1
+    }
+  ],
+  "meta": {
+    "created_at": "<ignored>"
+  },
+  "name": "ada"
+}
+`
+	if string(stored) != want {
+		t.Errorf("unexpected snapshot:\n%s", stored)
+	}
+
+	// Ignored values may change between runs.
+	if err := runner.Run(context.Background(), "flow"); err != nil {
+		t.Fatalf("expected ignored values not to be compared, got %v", err)
+	}
+
+	// Any other change fails with a diff.
+	name = "grace"
+	err = runner.Run(context.Background(), "flow")
+	if err == nil {
+		t.Fatal("expected a changed response to fail")
+	}
+	for _, s := range []string{"snapshot failed in step 'user'", "does not match snapshot", `-   "name": "ada"`, `+   "name": "grace"`} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("expected the error to contain %q, got: %v", s, err)
+		}
+	}
+
+	// Updating accepts the new response.
+	runner.UpdateSnapshots = true
+	if err := runner.Run(context.Background(), "flow"); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	runner.UpdateSnapshots = false
+	if err := runner.Run(context.Background(), "flow"); err != nil {
+		t.Fatalf("expected the updated snapshot to match, got %v", err)
+	}
+}
+
+func TestSnapshotNames(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "n="+r.URL.Query().Get("n"))
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	bundle := &Bundle{
+		BaseURL:  ts.URL,
+		Requests: map[string]Request{"get": {Method: "GET", URL: "/?n={{index}}"}},
+		Chains: map[string][]ChainStep{
+			"loop": {
+				{Repeat: 2, Steps: []ChainStep{{Request: "get", Snapshot: &Snapshot{}}}},
+				{Request: "get", Variables: map[string]string{"index": "x"}, Snapshot: &Snapshot{Name: "named/get"}},
+				{Request: "get", Variables: map[string]string{"index": "y"}, Snapshot: &Snapshot{Name: "named/get"}},
+				{Parallel: []ChainStep{
+					{Request: "get", Variables: map[string]string{"index": "a"}, Snapshot: &Snapshot{Name: "branch"}},
+					{Request: "get", Variables: map[string]string{"index": "b"}, Snapshot: &Snapshot{Name: "branch"}},
+				}},
+			},
+		},
+	}
+	runner := New(bundle)
+	runner.Out = &bytes.Buffer{}
+	runner.Log = nil
+	runner.SnapshotDir = dir
+
+	runner.UpdateSnapshots = true
+	if err := runner.Run(context.Background(), "loop"); err != nil {
+		t.Fatalf("saving run failed: %v", err)
+	}
+	runner.UpdateSnapshots = false
+	// Names do not depend on the order parallel steps finish in.
+	for i := 0; i < 5; i++ {
+		if err := runner.Run(context.Background(), "loop"); err != nil {
+			t.Fatalf("run %d failed: %v", i+1, err)
+		}
+	}
+	for file, want := range map[string]string{
+		"loop_get_1.snap":  "n=0",
+		"loop_get_2.snap":  "n=1",
+		"named_get.snap":   "n=x",
+		"named_get-2.snap": "n=y",
+		"branch_1.snap":    "n=a",
+		"branch_2.snap":    "n=b",
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Errorf("expected snapshot %s: %v", file, err)
+		} else if string(data) != want {
+			t.Errorf("expected %s to hold %q, got %q", file, want, data)
+		}
+	}
+
+	// Paths cannot be ignored in a body that is not JSON.
+	bundle.Chains["loop"][1].Snapshot.Ignore = []string{"$.id"}
+	err := runner.Run(context.Background(), "loop")
+	if err == nil || !strings.Contains(err.Error(), "not JSON") {
+		t.Errorf("expected an error for ignore paths on a plain body, got %v", err)
+	}
+}
+
+func TestCurlScriptSnapshot(t *testing.T) {
+	bundle := &Bundle{
+		BaseURL:  "https://api.etin.dev",
+		Requests: map[string]Request{"user": {Method: "GET", URL: "/user"}},
+		Chains: map[string][]ChainStep{
+			"flow": {{Request: "user", Snapshot: &Snapshot{}}},
+		},
+	}
+
+	_, err := New(bundle).CurlScript("flow")
+	if err == nil || !strings.Contains(err.Error(), "the response snapshot of step 'user' cannot be exported") {
+		t.Errorf("expected the snapshot not to be exported, got %v", err)
+	}
+}
+
+func TestLineDiff(t *testing.T) {
+	a := "a\nb\nc\nd\ne\nf\ng\n"
+	b := "a\nb\nc\nD\ne\nf\ng\nh\n"
+	want := "  ...\n  b\n  c\n- d\n+ D\n  e\n  f\n  g\n+ h"
+	if got := lineDiff(a, b); got != want {
+		t.Errorf("unexpected diff:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Large bodies are only compared where they differ, and not at all when
+	// that is too much.
+	var large, changed []string
+	for i := 0; i < 50000; i++ {
+		large = append(large, fmt.Sprintf("line %d", i))
+		changed = append(changed, fmt.Sprintf("line %d", i))
+	}
+	changed[25000] = "changed"
+	want = "  ...\n  line 24998\n  line 24999\n- line 25000\n+ changed\n  line 25001\n  line 25002\n  ..."
+	if got := lineDiff(strings.Join(large, "\n"), strings.Join(changed, "\n")); got != want {
+		t.Errorf("unexpected diff:\n%s\nwant:\n%s", got, want)
+	}
+	for i := range changed {
+		changed[i] = "changed"
+	}
+	if got := lineDiff(strings.Join(large, "\n"), strings.Join(changed, "\n")); got != "  (50000 lines differ, too many to compare)" {
+		t.Errorf("expected the diff to be skipped, got %.200s", got)
+	}
+}
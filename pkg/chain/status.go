@@ -0,0 +1,96 @@
+package chain
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// statusRange is an inclusive range of status codes matched by an on_status
+// key: an exact code such as 404, a wildcard such as 5xx, a range such as
+// 400-499, or default.
+type statusRange struct {
+	key      string
+	min, max int
+}
+
+// width is used to pick the most specific of several matching keys.
+func (s statusRange) width() int {
+	return s.max - s.min
+}
+
+func parseStatusKey(key string) (statusRange, error) {
+	k := strings.ToLower(strings.TrimSpace(key))
+	if k == "default" {
+		return statusRange{key: key, min: 0, max: 999}, nil
+	}
+
+	if from, to, ok := strings.Cut(k, "-"); ok {
+		min, errMin := strconv.Atoi(strings.TrimSpace(from))
+		max, errMax := strconv.Atoi(strings.TrimSpace(to))
+		if errMin != nil || errMax != nil || min > max {
+			return statusRange{}, fmt.Errorf("invalid on_status range '%s'", key)
+		}
+		return statusRange{key: key, min: min, max: max}, nil
+	}
+
+	if len(k) == 3 && strings.HasSuffix(k, "x") {
+		prefix := strings.TrimRight(k, "x")
+		if prefix == "" {
+			return statusRange{}, fmt.Errorf("invalid on_status key '%s'", key)
+		}
+		n, err := strconv.Atoi(prefix)
+		if err != nil {
+			return statusRange{}, fmt.Errorf("invalid on_status key '%s'", key)
+		}
+		scale := 1
+		for i := len(prefix); i < 3; i++ {
+			scale *= 10
+		}
+		return statusRange{key: key, min: n * scale, max: n*scale + scale - 1}, nil
+	}
+
+	code, err := strconv.Atoi(k)
+	if err != nil {
+		return statusRange{}, fmt.Errorf("invalid on_status key '%s'", key)
+	}
+	return statusRange{key: key, min: code, max: code}, nil
+}
+
+// statusRanges parses the keys of an on_status block, most specific first.
+func statusRanges(branches map[string][]ChainStep) ([]statusRange, error) {
+	ranges := make([]statusRange, 0, len(branches))
+	for key := range branches {
+		rng, err := parseStatusKey(key)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, rng)
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		if ranges[i].width() != ranges[j].width() {
+			return ranges[i].width() < ranges[j].width()
+		}
+		if ranges[i].min != ranges[j].min {
+			return ranges[i].min < ranges[j].min
+		}
+		return ranges[i].key < ranges[j].key
+	})
+	return ranges, nil
+}
+
+// matchStatus returns the key of the most specific on_status branch matching
+// code, or false if none does.
+func matchStatus(branches map[string][]ChainStep, code int) (string, bool, error) {
+	ranges, err := statusRanges(branches)
+	if err != nil {
+		return "", false, err
+	}
+	for _, rng := range ranges {
+		if code >= rng.min && code <= rng.max {
+			return rng.key, true, nil
+		}
+	}
+	return "", false, nil
+}
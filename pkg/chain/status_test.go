@@ -0,0 +1,153 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMatchStatus(t *testing.T) {
+	branches := map[string][]ChainStep{
+		"404":     nil,
+		"4xx":     nil,
+		"400-499": nil,
+		"50x":     nil,
+		"5xx":     nil,
+		"default": nil,
+	}
+
+	tests := []struct {
+		code     int
+		expected string
+	}{
+		{404, "404"},
+		{401, "400-499"},
+		{503, "50x"},
+		{599, "5xx"},
+		{200, "default"},
+	}
+	for _, tt := range tests {
+		key, ok, err := matchStatus(branches, tt.code)
+		if err != nil || !ok || key != tt.expected {
+			t.Errorf("matchStatus(%d) = %q, %v, %v, expected %q", tt.code, key, ok, err, tt.expected)
+		}
+	}
+
+	if _, ok, _ := matchStatus(map[string][]ChainStep{"5xx": nil}, 200); ok {
+		t.Error("expected 200 not to match 5xx")
+	}
+	for _, key := range []string{"5yy", "499-400", "xxx", "abc"} {
+		if _, _, err := matchStatus(map[string][]ChainStep{key: nil}, 200); err == nil {
+			t.Errorf("expected key %q to be invalid", key)
+		}
+	}
+}
+
+func TestOnStatusRangesAndOnError(t *testing.T) {
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if code, err := strconv.Atoi(r.URL.Query().Get("code")); err == nil {
+			w.WriteHeader(code)
+		}
+	}))
+	defer ts.Close()
+
+	bundle := &Bundle{
+		BaseURL: ts.URL,
+		Requests: map[string]Request{
+			"call":     {Method: "GET", URL: "/call?code={{code}}"},
+			"fallback": {Method: "GET", URL: "/fallback"},
+			"default":  {Method: "GET", URL: "/default"},
+			"down":     {Method: "GET", URL: "http://127.0.0.1:1/down"},
+		},
+		Chains: map[string][]ChainStep{
+			"flow": {
+				{
+					Request:   "call",
+					Variables: map[string]string{"code": "503"},
+					OnStatus: map[string][]ChainStep{
+						"5xx":     {{Request: "fallback"}},
+						"default": {{Request: "default"}},
+					},
+				},
+				{
+					Request:   "call",
+					Variables: map[string]string{"code": "201"},
+					OnStatus: map[string][]ChainStep{
+						"5xx":     {{Request: "fallback"}},
+						"default": {{Request: "default"}},
+					},
+				},
+				{
+					Request: "down",
+					OnError: []ChainStep{{Request: "fallback", If: "exists error"}},
+				},
+			},
+		},
+	}
+
+	var log bytes.Buffer
+	runner := New(bundle)
+	runner.Out = &bytes.Buffer{}
+	runner.Log = &log
+
+	if err := runner.Run(context.Background(), "flow"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if got := strings.Join(paths, ","); got != "/call,/fallback,/call,/default,/fallback" {
+		t.Errorf("unexpected requests %s", got)
+	}
+	if !strings.Contains(log.String(), "Status 503 matched '5xx', executing branch...") {
+		t.Errorf("expected matched range to be logged, got %q", log.String())
+	}
+
+	// Without an error branch a transport error still fails the chain.
+	bundle.Chains["flow"] = []ChainStep{{Request: "down"}}
+	if err := runner.Run(context.Background(), "flow"); err == nil {
+		t.Error("expected the chain to fail")
+	}
+}
+
+func TestCurlScriptStatusRanges(t *testing.T) {
+	bundle := &Bundle{
+		BaseURL: "https://api.etin.dev",
+		Requests: map[string]Request{
+			"get":   {Method: "GET", URL: "/"},
+			"retry": {Method: "GET", URL: "/retry"},
+			"login": {Method: "POST", URL: "/login"},
+		},
+		Chains: map[string][]ChainStep{
+			"flow": {{
+				Request: "get",
+				OnStatus: map[string][]ChainStep{
+					"5xx":     {{Request: "retry"}},
+					"401":     {{Request: "login"}},
+					"default": {{Request: "retry"}},
+				},
+				OnError: []ChainStep{{Request: "retry"}},
+			}},
+		},
+	}
+
+	got, err := New(bundle).CurlScript("flow")
+	if err != nil {
+		t.Fatalf("CurlScript failed: %v", err)
+	}
+
+	for _, want := range []string{
+		"if ! response=$(curl -sS -w '\\n%{http_code}' \"https://api.etin.dev/\"); then\n  error='step '\\''get'\\'' failed'\n",
+		"  if [ \"$status\" -eq 401 ]; then\n",
+		"  elif [ \"$status\" -ge 500 ] && [ \"$status\" -le 599 ]; then\n",
+		"  else\n",
+		"  fi\nfi\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected script to contain %q, got:\n%s", want, got)
+		}
+	}
+}
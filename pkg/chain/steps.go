@@ -0,0 +1,263 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (r *Runner) executeSteps(ctx context.Context, steps []ChainStep, variables map[string]interface{}) error {
+	for i, step := range steps {
+		if err := r.executeStep(ctx, i, step, variables); err != nil {
+			if !step.ContinueOnError || ctx.Err() != nil {
+				return err
+			}
+			if err := r.deferFailure(err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// executeStep runs the i-th step of a list.
+func (r *Runner) executeStep(ctx context.Context, i int, step ChainStep, variables map[string]interface{}) error {
+	if step.If != "" {
+		ok, err := evaluateCondition(step.If, variables)
+		if err != nil {
+			return fmt.Errorf("invalid 'if' in step %d: %w", i+1, err)
+		}
+		if !ok {
+			if len(step.Else) == 0 {
+				fmt.Fprintf(r.log(), "Skipping step %d, condition '%s' is false\n", i+1, step.If)
+				r.recordSkipped(step)
+				return nil
+			}
+			fmt.Fprintf(r.log(), "Condition '%s' is false, executing else branch...\n", step.If)
+			if err := r.executeSteps(ctx, step.Else, variables); err != nil {
+				return fmt.Errorf("else branch failed: %w", err)
+			}
+			return nil
+		}
+	}
+	if len(step.Parallel) > 0 {
+		return r.executeParallel(ctx, step, variables)
+	}
+	if step.Foreach != "" || step.Repeat > 0 {
+		if err := r.executeLoop(ctx, step, variables); err != nil {
+			return fmt.Errorf("loop in step %d failed: %w", i+1, err)
+		}
+		return nil
+	}
+	if step.Chain != "" {
+		if err := r.executeChain(ctx, step, variables); err != nil {
+			return err
+		}
+		// The chain's own steps are reported, so the step itself only is
+		// when it has assertions.
+		if len(step.Assert) > 0 {
+			start := time.Now()
+			results, err := checkAssertions(step.Assert, variables, nil)
+			if err != nil {
+				err = fmt.Errorf("assertion failed in step '%s': %w", step.Chain, err)
+			}
+			r.record(step, start, nil, results, err)
+			return r.softFailure(err)
+		}
+		return nil
+	}
+	if step.Request == "" && len(step.Steps) > 0 {
+		return r.executeSteps(ctx, step.Steps, variables)
+	}
+	if step.Request == "" {
+		return fmt.Errorf("step %d missing 'request' field", i+1)
+	}
+
+	fmt.Fprintf(r.log(), "Running step: %s\n", step.Request)
+
+	start := time.Now()
+	var resp *response
+	var err error
+	if step.Until != nil {
+		resp, err = r.pollStep(ctx, step, variables)
+	} else if resp, err = r.runStep(ctx, step, variables); err != nil {
+		err = fmt.Errorf("step '%s' failed: %w", step.Request, err)
+	}
+	if err != nil {
+		var transportErr *transportError
+		if len(step.OnError) == 0 || !errors.As(err, &transportErr) {
+			r.record(step, start, resp, nil, err)
+			return err
+		}
+		r.recordRecovered(step, start, resp, err)
+		fmt.Fprintf(r.log(), "Step '%s' failed, executing error branch...\n", step.Request)
+		variables["error"] = err.Error()
+		if err := r.executeSteps(ctx, step.OnError, variables); err != nil {
+			return fmt.Errorf("error branch failed: %w", err)
+		}
+		return nil
+	}
+
+	if step.Schema != "" {
+		if err := r.validateSchema(step, resp); err != nil {
+			err = fmt.Errorf("schema validation failed in step '%s': %w", step.Request, err)
+			r.record(step, start, resp, nil, err)
+			return err
+		}
+	}
+
+	if step.Snapshot != nil {
+		if err := r.checkSnapshot(step, resp); err != nil {
+			err = fmt.Errorf("snapshot failed in step '%s': %w", step.Request, err)
+			r.record(step, start, resp, nil, err)
+			return err
+		}
+	}
+
+	// Assertions. A polled step has already passed them, so checking
+	// again only collects the results.
+	results, err := checkAssertions(step.Assert, variables, resp)
+	if err != nil {
+		err = fmt.Errorf("assertion failed in step '%s': %w", step.Request, err)
+	}
+	r.record(step, start, resp, results, err)
+	if err := r.softFailure(err); err != nil {
+		return err
+	}
+
+	// Branching
+	respStatusCode := resp.status()
+	key, ok, err := matchStatus(step.OnStatus, respStatusCode)
+	if err != nil {
+		return fmt.Errorf("step '%s': %w", step.Request, err)
+	}
+	if ok {
+		fmt.Fprintf(r.log(), "Status %d matched '%s', executing branch...\n", respStatusCode, key)
+		if err := r.executeSteps(ctx, step.OnStatus[key], variables); err != nil {
+			return fmt.Errorf("branch execution failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// runStep makes the step's request and extracts variables from the response.
+func (r *Runner) runStep(ctx context.Context, step ChainStep, variables map[string]interface{}) (*response, error) {
+	// Merge step-level variables (mapping/overrides), e.g. map "token_b" to "token"
+	// without polluting the chain scope.
+	stepVars := make(map[string]interface{})
+	for k, v := range variables {
+		stepVars[k] = v
+	}
+	for k, v := range step.Variables {
+		// Substitute values from chain variables
+		// e.g. v="{{token_b}}", we look up token_b in variables
+		stepVars[k] = substitute(v, variables)
+	}
+
+	resp, err := r.runSavedRequest(ctx, step.Request, stepVars, &step)
+	if err != nil {
+		return resp, err
+	}
+
+	return resp, r.extract(step, resp, variables)
+}
+
+func executeAssertions(assertions []Assertion, vars map[string]interface{}, resp *response) error {
+	_, err := checkAssertions(assertions, vars, resp)
+	return err
+}
+
+// checkAssertions evaluates assertions in order up to the first that fails,
+// returning the results of those evaluated. Failed soft assertions do not stop
+// the others, and when nothing else fails they are returned together as a
+// *softAssertionError. With a response, the left side of
+// an assertion can also refer to it directly, such as status or
+// header.Content-Type.
+func checkAssertions(assertions []Assertion, vars map[string]interface{}, resp *response) ([]AssertionResult, error) {
+	var doc *responseDocument
+	if resp != nil {
+		doc = &responseDocument{resp: resp}
+	}
+
+	var results []AssertionResult
+	var soft []string
+	for i, a := range assertions {
+		left := resolveOperand(a.Left, vars)
+		if source, ok := responseSource(a.Left); ok && doc != nil {
+			left = doc.operand(source)
+		}
+		right := resolveOperand(a.Right, vars)
+
+		pass, err := evaluate(left, a.Op, right)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, AssertionResult{Left: left.display(), Op: a.Op, Right: right.display(), Passed: pass, Soft: a.Soft})
+		if pass {
+			continue
+		}
+		failure := fmt.Sprintf("assertion %d failed: '%s' %s '%s'", i+1, left.display(), a.Op, right.display())
+		if left.err != nil {
+			failure = fmt.Sprintf("assertion %d failed: %v", i+1, left.err)
+		}
+		if !a.Soft {
+			return results, errors.New(failure)
+		}
+		soft = append(soft, failure)
+	}
+	if len(soft) > 0 {
+		return results, &softAssertionError{failures: soft}
+	}
+	return results, nil
+}
+
+// softFailure defers err when only soft assertions failed, and returns any
+// other error.
+func (r *Runner) softFailure(err error) error {
+	var soft *softAssertionError
+	if errors.As(err, &soft) {
+		return r.deferFailure(err)
+	}
+	return err
+}
+
+// compare applies a comparison operator to two substituted values. Equality
+// compares the text, and the other operators compare numbers when both values
+// are.
+func compare(left, op, right string) (bool, error) {
+	leftNum, errL := strconv.ParseFloat(strings.TrimSpace(left), 64)
+	rightNum, errR := strconv.ParseFloat(strings.TrimSpace(right), 64)
+	isNumeric := errL == nil && errR == nil
+
+	switch op {
+	case "==":
+		return left == right, nil
+	case "!=":
+		return left != right, nil
+	case ">":
+		if isNumeric {
+			return leftNum > rightNum, nil
+		}
+		return left > right, nil
+	case ">=":
+		if isNumeric {
+			return leftNum >= rightNum, nil
+		}
+		return left >= right, nil
+	case "<":
+		if isNumeric {
+			return leftNum < rightNum, nil
+		}
+		return left < right, nil
+	case "<=":
+		if isNumeric {
+			return leftNum <= rightNum, nil
+		}
+		return left <= right, nil
+	default:
+		return false, fmt.Errorf("unknown operator '%s'", op)
+	}
+}
@@ -0,0 +1,126 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestStrictMode(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		fmt.Fprint(w, `{"user":{"id":1}}`)
+	}))
+	defer ts.Close()
+
+	bundle := &Bundle{
+		BaseURL: ts.URL,
+		Requests: map[string]Request{
+			"login": {Method: "POST", URL: "/login"},
+			"me": {
+				Method:  "GET",
+				URL:     "/users/{{user_id}}",
+				Headers: []string{"Authorization: Bearer {{token}}"},
+			},
+		},
+		Chains: map[string][]ChainStep{
+			"extract_miss": {{Request: "login", Extract: map[string]string{"token": "$.token", "user_id": "$.user.id"}}},
+			"unresolved":   {{Request: "me"}},
+		},
+	}
+
+	runner := New(bundle)
+	runner.Out = &bytes.Buffer{}
+	runner.Log = nil
+	runner.Strict = true
+
+	err := runner.Run(context.Background(), "extract_miss")
+	if err == nil || !strings.Contains(err.Error(), "failed to extract 'token' using '$.token'") {
+		t.Errorf("expected an extraction error, got %v", err)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	err = runner.Run(context.Background(), "unresolved")
+	if err == nil || !strings.Contains(err.Error(), "unresolved variables: token, user_id") {
+		t.Errorf("expected unresolved variables to be listed, got %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("expected no request to be sent, got %d", calls)
+	}
+
+	// A chain can opt out of strict mode.
+	lenient := false
+	bundle.ChainOptions = map[string]ChainOptions{"unresolved": {Strict: &lenient}}
+	if err := runner.Run(context.Background(), "unresolved"); err != nil {
+		t.Errorf("expected the lenient chain to run, got %v", err)
+	}
+
+	// Without strict mode misses are warnings.
+	var log bytes.Buffer
+	runner.Strict = false
+	runner.Log = &log
+	if err := runner.Run(context.Background(), "extract_miss"); err != nil {
+		t.Errorf("expected the chain to run, got %v", err)
+	}
+	if !strings.Contains(log.String(), "Warning: failed to extract 'token'") {
+		t.Errorf("expected a warning, got %q", log.String())
+	}
+}
+
+func TestStrictModeStatusBranch(t *testing.T) {
+	var calls []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.URL.Path)
+		if r.URL.Path == "/signup" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"exists"}`)
+			return
+		}
+		fmt.Fprint(w, `{"token":"abc"}`)
+	}))
+	defer ts.Close()
+
+	bundle := &Bundle{
+		BaseURL: ts.URL,
+		Requests: map[string]Request{
+			"signup": {Method: "POST", URL: "/signup"},
+			"login":  {Method: "POST", URL: "/login"},
+		},
+		Chains: map[string][]ChainStep{
+			"flow": {{
+				Request:  "signup",
+				Extract:  map[string]string{"token": "$.token"},
+				OnStatus: map[string][]ChainStep{"400": {{Request: "login", Extract: map[string]string{"token": "$.token"}}}},
+			}},
+		},
+	}
+	var log bytes.Buffer
+	runner := New(bundle)
+	runner.Out = &bytes.Buffer{}
+	runner.Log = &log
+	runner.Strict = true
+
+	// The branch handling the status runs instead of the miss failing the step.
+	if err := runner.Run(context.Background(), "flow"); err != nil {
+		t.Fatalf("expected the status branch to run, got %v", err)
+	}
+	if strings.Join(calls, " ") != "/signup /login" {
+		t.Errorf("expected the fallback to log in, got %v", calls)
+	}
+	if !strings.Contains(log.String(), "Warning: failed to extract 'token'") {
+		t.Errorf("expected the miss to be a warning, got %q", log.String())
+	}
+
+	// Statuses without a branch still fail on misses.
+	bundle.Chains["flow"][0].OnStatus = map[string][]ChainStep{"401": {{Request: "login"}}}
+	err := runner.Run(context.Background(), "flow")
+	if err == nil || !strings.Contains(err.Error(), "failed to extract 'token'") {
+		t.Errorf("expected an extraction error, got %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// executeChain runs the chain named by a step in its own variable scope. The
+// scope starts as a copy of the caller's variables plus the step's variables,
+// and only the declared outputs are copied back to the caller.
+func (r *Runner) executeChain(ctx context.Context, step ChainStep, variables map[string]interface{}) error {
+	sub, steps, err := r.enterChain(step.Chain)
+	if err != nil {
+		return err
+	}
+
+	scope := make(map[string]interface{}, len(variables)+len(step.Variables))
+	for k, v := range variables {
+		scope[k] = v
+	}
+	for k, v := range step.Variables {
+		scope[k] = substitute(v, variables)
+	}
+
+	fmt.Fprintf(r.log(), "Running chain: %s\n", step.Chain)
+	if err := sub.executeSteps(ctx, steps, scope); err != nil {
+		return fmt.Errorf("chain '%s' failed: %w", step.Chain, err)
+	}
+
+	for _, name := range r.chainOutputs(step) {
+		v, ok := scope[name]
+		if !ok {
+			return fmt.Errorf("chain '%s' did not set output '%s'", step.Chain, name)
+		}
+		variables[name] = v
+	}
+	return nil
+}
+
+// enterChain returns the named chain's steps and a copy of the runner for
+// running them, with the chain's options applied and the chain pushed onto the
+// call stack. A chain that is already on the stack is reported as a cycle.
+func (r *Runner) enterChain(name string) (*Runner, []ChainStep, error) {
+	key, ok := lookupName(r.Bundle.Chains, name)
+	if !ok {
+		return nil, nil, fmt.Errorf("chain '%s' not found in config", name)
+	}
+	// The saved name is used from here on, so a cycle is found however the
+	// steps spell it.
+	name = key
+	steps := r.Bundle.Chains[name]
+	for _, caller := range r.callStack {
+		if caller == name {
+			cycle := append(append([]string{}, r.callStack...), name)
+			return nil, nil, fmt.Errorf("chain '%s' calls itself: %s", name, strings.Join(cycle, " -> "))
+		}
+	}
+
+	sub := *r
+	sub.callStack = append(append([]string{}, r.callStack...), name)
+	if opts := r.Bundle.ChainOptions[name]; opts.Strict != nil {
+		sub.Strict = *opts.Strict
+	}
+	return &sub, steps, nil
+}
+
+// chainOutputs returns the variables a chain step exports to its caller: the
+// step's own outputs, or else those declared by the chain.
+func (r *Runner) chainOutputs(step ChainStep) []string {
+	if len(step.Outputs) > 0 {
+		return step.Outputs
+	}
+	key, _ := lookupName(r.Bundle.ChainOptions, step.Chain)
+	return r.Bundle.ChainOptions[key].Outputs
+}
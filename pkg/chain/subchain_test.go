@@ -0,0 +1,109 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSubChain(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			fmt.Fprintf(w, `{"token":"tok-%s","session":"s1"}`, r.URL.Query().Get("user"))
+		case "/me":
+			fmt.Fprintf(w, `{"auth":"%s"}`, r.Header.Get("Authorization"))
+		}
+	}))
+	defer ts.Close()
+
+	bundle := &Bundle{
+		BaseURL: ts.URL,
+		Requests: map[string]Request{
+			"login": {Method: "POST", URL: "/login?user={{username}}"},
+			"me":    {Method: "GET", URL: "/me", Headers: []string{"Authorization: Bearer {{token}}"}},
+		},
+		Chains: map[string][]ChainStep{
+			"login_flow": {{Request: "login", Extract: map[string]string{"token": "$.token", "session": "$.session"}}},
+			"flow": {
+				{Chain: "login_flow", Variables: map[string]string{"username": "{{name}}"}},
+				{Request: "me", Extract: map[string]string{"auth": "$.auth"}},
+			},
+		},
+		ChainOptions: map[string]ChainOptions{"login_flow": {Outputs: []string{"token"}}},
+	}
+
+	runner := New(bundle)
+	runner.Out = &bytes.Buffer{}
+	runner.Log = nil
+
+	variables := map[string]interface{}{"name": "ada"}
+	if err := runner.executeSteps(context.Background(), bundle.Chains["flow"], variables); err != nil {
+		t.Fatalf("executeSteps failed: %v", err)
+	}
+	if variables["auth"] != "Bearer tok-ada" {
+		t.Errorf("expected the output token to be used, got %v", variables["auth"])
+	}
+	for _, name := range []string{"session", "username"} {
+		if _, ok := variables[name]; ok {
+			t.Errorf("expected '%s' not to leave the sub-chain", name)
+		}
+	}
+
+	// An output the chain does not set fails the step.
+	bundle.Chains["flow"][0].Outputs = []string{"user_id"}
+	err := runner.executeSteps(context.Background(), bundle.Chains["flow"], map[string]interface{}{"name": "ada"})
+	if err == nil || !strings.Contains(err.Error(), "chain 'login_flow' did not set output 'user_id'") {
+		t.Errorf("expected a missing output error, got %v", err)
+	}
+}
+
+func TestSubChainCycle(t *testing.T) {
+	bundle := &Bundle{
+		Chains: map[string][]ChainStep{
+			"a": {{Chain: "b"}},
+			"b": {{Steps: []ChainStep{{Chain: "a"}}}},
+		},
+	}
+
+	runner := New(bundle)
+	runner.Out = &bytes.Buffer{}
+	runner.Log = nil
+
+	err := runner.Run(context.Background(), "a")
+	if err == nil || !strings.Contains(err.Error(), "chain 'a' calls itself: a -> b -> a") {
+		t.Errorf("expected a cycle error, got %v", err)
+	}
+	if _, err := runner.CurlScript("b"); err == nil || !strings.Contains(err.Error(), "b -> a -> b") {
+		t.Errorf("expected a cycle error from the export, got %v", err)
+	}
+}
+
+func TestCurlScriptSubChain(t *testing.T) {
+	bundle := &Bundle{
+		BaseURL:  "https://api.etin.dev",
+		Requests: map[string]Request{"login": {Method: "POST", URL: "/login?user={{username}}"}},
+		Chains: map[string][]ChainStep{
+			"login_flow": {{Request: "login", Extract: map[string]string{"token": "$.token"}}},
+			"flow":       {{Chain: "login_flow", Variables: map[string]string{"username": "{{name}}"}}},
+		},
+	}
+
+	got, err := New(bundle).CurlScript("flow")
+	if err != nil {
+		t.Fatalf("CurlScript failed: %v", err)
+	}
+
+	for _, want := range []string{
+		"# Chain: login_flow\nusername=\"${name}\"\n\n# Step: login\n",
+		`token=$(printf '%s' "$body" | jq -rc '.token')`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected script to contain %q, got:\n%s", want, got)
+		}
+	}
+}
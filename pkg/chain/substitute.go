@@ -0,0 +1,100 @@
+package chain
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// placeholderPattern matches a {{placeholder}} and captures its name.
+var placeholderPattern = regexp.MustCompile(`\{\{([^{}]+)\}\}`)
+
+func substitute(tmpl string, vars map[string]interface{}) string {
+	return replacePlaceholders(substituteDynamic(tmpl), vars, func(value string, _ int) string {
+		return value
+	})
+}
+
+func substituteURL(tmpl string, vars map[string]interface{}) string {
+	return replacePlaceholders(substituteDynamic(tmpl), vars, func(value string, start int) string {
+		// A placeholder at the start stands for the base of the URL, e.g.
+		// {{base_url}}/users, so it is not escaped.
+		if start == 0 {
+			return value
+		}
+		return url.QueryEscape(value)
+	})
+}
+
+// replacePlaceholders replaces the {{placeholders}} of the variables that are
+// set with their values, passed through render along with the offset of the
+// placeholder. Others are left as they are.
+func replacePlaceholders(tmpl string, vars map[string]interface{}, render func(value string, start int) string) string {
+	matches := placeholderPattern.FindAllStringSubmatchIndex(tmpl, -1)
+	if len(matches) == 0 {
+		return tmpl
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		v, ok := lookupVariable(vars, tmpl[m[2]:m[3]])
+		if !ok {
+			continue
+		}
+		b.WriteString(tmpl[last:m[0]])
+		b.WriteString(render(formatValue(v), m[0]))
+		last = m[1]
+	}
+	b.WriteString(tmpl[last:])
+	return b.String()
+}
+
+// formatValue returns the text a variable is substituted with. Objects and
+// arrays, e.g. the items of a foreach over a list of objects, are written as
+// JSON rather than in Go's notation.
+func formatValue(v interface{}) string {
+	if v != nil {
+		switch reflect.TypeOf(v).Kind() {
+		case reflect.Map, reflect.Slice, reflect.Array:
+			if data, err := json.Marshal(v); err == nil {
+				return string(data)
+			}
+		}
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// lookupVariable returns the named variable. Like the names of requests and
+// chains, variable names are matched case-insensitively when none matches
+// exactly, since viper lowercases those read from a config file.
+func lookupVariable(vars map[string]interface{}, name string) (interface{}, bool) {
+	key, ok := lookupName(vars, name)
+	return vars[key], ok
+}
+
+// substituteDynamic replaces the built-in dynamic variables.
+func substituteDynamic(tmpl string) string {
+	if strings.Contains(tmpl, "{{$timestamp}}") {
+		tmpl = strings.ReplaceAll(tmpl, "{{$timestamp}}", fmt.Sprintf("%d", time.Now().Unix()))
+	}
+	if strings.Contains(tmpl, "{{$uuid}}") {
+		// Simple random "UUID" - good enough for now
+		uuid := fmt.Sprintf("%x", rand.Int63())
+		tmpl = strings.ReplaceAll(tmpl, "{{$uuid}}", uuid)
+	}
+	return tmpl
+}
+
+// variableName accepts a variable name with or without braces.
+func variableName(s string) string {
+	name := strings.TrimSpace(s)
+	if strings.HasPrefix(name, "{{") && strings.HasSuffix(name, "}}") {
+		name = strings.TrimSpace(name[2 : len(name)-2])
+	}
+	return name
+}
@@ -0,0 +1,27 @@
+package chain
+
+import "testing"
+
+func TestSubstituteURL(t *testing.T) {
+	vars := map[string]interface{}{
+		"base_url": "https://api.etin.dev/v1",
+		"email":    "ada+test@etin.dev",
+	}
+
+	got := substituteURL("{{base_url}}/users?email={{email}}", vars)
+	if got != "https://api.etin.dev/v1/users?email=ada%2Btest%40etin.dev" {
+		t.Errorf("unexpected URL %s", got)
+	}
+}
+
+func TestSubstituteMixedCase(t *testing.T) {
+	vars := map[string]interface{}{"userid": 42, "userId": "exact", "team": "a"}
+
+	if got := substitute("{{userId}}/{{TEAM}}/{{missing}}", vars); got != "exact/a/{{missing}}" {
+		t.Errorf("unexpected substitution %s", got)
+	}
+	delete(vars, "userId")
+	if got := substitute("{{userId}}", vars); got != "42" {
+		t.Errorf("expected a case-insensitive match, got %s", got)
+	}
+}
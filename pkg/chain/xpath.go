@@ -0,0 +1,368 @@
+package chain
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// xmlNode is an element or text node of a parsed XML or HTML document. Text
+// nodes have no name.
+type xmlNode struct {
+	name     string
+	attrs    []xml.Attr
+	text     string
+	parent   *xmlNode
+	children []*xmlNode
+}
+
+// parseXML parses an XML document, or HTML leniently, into a tree under an
+// unnamed root node.
+func parseXML(body []byte) (*xmlNode, error) {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	dec.Strict = false
+	dec.AutoClose = xml.HTMLAutoClose
+	dec.Entity = xml.HTMLEntity
+
+	root := &xmlNode{}
+	current := root
+	elements := 0
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			node := &xmlNode{name: t.Name.Local, attrs: t.Attr, parent: current}
+			current.children = append(current.children, node)
+			current = node
+			elements++
+		case xml.EndElement:
+			// Close up to the matching element, tolerating unclosed tags.
+			for n := current; n != root; n = n.parent {
+				if strings.EqualFold(n.name, t.Name.Local) {
+					current = n.parent
+					break
+				}
+			}
+		case xml.CharData:
+			current.children = append(current.children, &xmlNode{text: string(t), parent: current})
+		}
+	}
+	if elements == 0 {
+		return nil, fmt.Errorf("no elements found")
+	}
+	return root, nil
+}
+
+// textContent returns the concatenated text of a node and its descendants.
+func (n *xmlNode) textContent() string {
+	if n.name == "" && n.parent != nil {
+		return n.text
+	}
+	var b strings.Builder
+	for _, child := range n.children {
+		b.WriteString(child.textContent())
+	}
+	return b.String()
+}
+
+func (n *xmlNode) attr(name string) (string, bool) {
+	for _, a := range n.attrs {
+		if strings.EqualFold(a.Name.Local, name) {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// xpathNodeTest matches the node tests xpathLookup supports, and
+// xpathPredicateOperand the left side of the predicates it supports. Anything
+// else, such as functions or the or and and operators, is rejected rather than
+// read as an element name.
+var (
+	xpathNodeTest         = regexp.MustCompile(`^(@?[\w.:-]+|\*|text\(\)|node\(\))$`)
+	xpathPredicateOperand = regexp.MustCompile(`^(@?[\w.:-]+|text\(\))$`)
+)
+
+// xpathStep is one location step of a path, such as //li[2] or @href.
+type xpathStep struct {
+	descendant bool
+	test       string
+	predicates []string
+}
+
+// xpathLookup evaluates a subset of XPath against a document: child (/) and
+// descendant (//) steps, element names or *, text(), @attribute, . and .., and
+// predicates by position ([1], [last()]), attribute ([@id], [@id='x'],
+// [@id!='x']) and child text ([name='x'], [text()!='x']). It returns the trimmed text of a
+// single match, or a list when several match.
+func xpathLookup(root *xmlNode, path string) (interface{}, error) {
+	steps, err := parseXPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []*xmlNode{root}
+	var values []string
+	for i, step := range steps {
+		if strings.HasPrefix(step.test, "@") {
+			if i != len(steps)-1 {
+				return nil, fmt.Errorf("attribute must be the last step of '%s'", path)
+			}
+			// Attributes belong to the context nodes themselves, or with //
+			// to any of their descendants too.
+			owners := nodes
+			if step.descendant {
+				owners = uniqueNodes(append(owners, xpathCandidates(nodes, true, "*")...))
+			}
+			for _, n := range owners {
+				if v, ok := n.attr(step.test[1:]); ok {
+					values = append(values, v)
+				}
+			}
+			nodes = nil
+			break
+		}
+
+		nodes, err = xpathStepNodes(nodes, step)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, n := range nodes {
+		values = append(values, strings.TrimSpace(n.textContent()))
+	}
+
+	switch len(values) {
+	case 0:
+		return nil, fmt.Errorf("no match for '%s'", path)
+	case 1:
+		return values[0], nil
+	default:
+		list := make([]interface{}, len(values))
+		for i, v := range values {
+			list[i] = v
+		}
+		return list, nil
+	}
+}
+
+func parseXPath(path string) ([]xpathStep, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, fmt.Errorf("empty path")
+	}
+
+	var steps []xpathStep
+	for i := 0; i < len(path); {
+		step := xpathStep{}
+		if strings.HasPrefix(path[i:], "//") {
+			step.descendant = true
+			i += 2
+		} else if path[i] == '/' {
+			i++
+		}
+
+		start := i
+		for i < len(path) && path[i] != '/' && path[i] != '[' {
+			i++
+		}
+		step.test = strings.TrimSpace(path[start:i])
+		if step.test == "" {
+			return nil, fmt.Errorf("invalid path '%s'", path)
+		}
+		if !xpathNodeTest.MatchString(step.test) {
+			return nil, fmt.Errorf("unsupported XPath '%s' in '%s'", step.test, path)
+		}
+
+		for i < len(path) && path[i] == '[' {
+			end := closingBracket(path, i)
+			if end < 0 {
+				return nil, fmt.Errorf("missing ']' in '%s'", path)
+			}
+			step.predicates = append(step.predicates, strings.TrimSpace(path[i+1:end]))
+			i = end + 1
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// closingBracket returns the index of the ']' closing the '[' at start,
+// skipping quoted strings.
+func closingBracket(path string, start int) int {
+	var quote byte
+	for i := start + 1; i < len(path); i++ {
+		switch c := path[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ']':
+			return i
+		}
+	}
+	return -1
+}
+
+// xpathCandidates returns the children of nodes, or all their descendants,
+// that match a node test. A node is returned once even when it descends from
+// several of the nodes, e.g. for //a//b with nested a elements.
+func xpathCandidates(nodes []*xmlNode, descendant bool, test string) []*xmlNode {
+	var out []*xmlNode
+	seen := make(map[*xmlNode]bool)
+	var visit func(n *xmlNode)
+	visit = func(n *xmlNode) {
+		for _, child := range n.children {
+			if xpathMatches(child, test) && !seen[child] {
+				seen[child] = true
+				out = append(out, child)
+			}
+			if descendant && child.name != "" {
+				visit(child)
+			}
+		}
+	}
+	for _, n := range nodes {
+		visit(n)
+	}
+	return out
+}
+
+// uniqueNodes drops repeated nodes, keeping the first of each.
+func uniqueNodes(nodes []*xmlNode) []*xmlNode {
+	seen := make(map[*xmlNode]bool, len(nodes))
+	out := nodes[:0:0]
+	for _, n := range nodes {
+		if !seen[n] {
+			seen[n] = true
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+func xpathMatches(n *xmlNode, test string) bool {
+	switch test {
+	case "text()":
+		return n.name == ""
+	case "*", "node()":
+		return n.name != "" || test == "node()"
+	default:
+		return n.name != "" && strings.EqualFold(n.name, test)
+	}
+}
+
+func xpathStepNodes(nodes []*xmlNode, step xpathStep) ([]*xmlNode, error) {
+	var candidates []*xmlNode
+	switch step.test {
+	case ".":
+		candidates = nodes
+	case "..":
+		for _, n := range nodes {
+			if n.parent != nil {
+				candidates = append(candidates, n.parent)
+			}
+		}
+		candidates = uniqueNodes(candidates)
+	default:
+		candidates = xpathCandidates(nodes, step.descendant, step.test)
+	}
+
+	for _, pred := range step.predicates {
+		var err error
+		if candidates, err = xpathFilter(candidates, pred); err != nil {
+			return nil, err
+		}
+	}
+	return candidates, nil
+}
+
+// xpathFilter applies a predicate. Positions count among siblings with the
+// same parent, as in XPath.
+func xpathFilter(nodes []*xmlNode, pred string) ([]*xmlNode, error) {
+	// A position of -1 stands for last().
+	position, isPosition := 0, false
+	if pred == "last()" {
+		position, isPosition = -1, true
+	} else if n, err := strconv.Atoi(pred); err == nil {
+		position, isPosition = n, true
+	}
+
+	if isPosition {
+		groups := make(map[*xmlNode][]*xmlNode)
+		var parents []*xmlNode
+		for _, n := range nodes {
+			if _, ok := groups[n.parent]; !ok {
+				parents = append(parents, n.parent)
+			}
+			groups[n.parent] = append(groups[n.parent], n)
+		}
+		var out []*xmlNode
+		for _, p := range parents {
+			group := groups[p]
+			idx := position - 1
+			if position == -1 {
+				idx = len(group) - 1
+			}
+			if idx >= 0 && idx < len(group) {
+				out = append(out, group[idx])
+			}
+		}
+		return out, nil
+	}
+
+	left, right, hasValue := strings.Cut(pred, "=")
+	unsupported := fmt.Errorf("unsupported XPath predicate '[%s]'", pred)
+	// With != a node matches when one of its values differs, so one without
+	// any value does not match either.
+	notEqual := hasValue && strings.HasSuffix(left, "!")
+	left = strings.TrimSpace(strings.TrimSuffix(left, "!"))
+	var want string
+	if hasValue {
+		right = strings.TrimSpace(right)
+		if len(right) < 2 || (right[0] != '\'' && right[0] != '"') || right[len(right)-1] != right[0] ||
+			strings.IndexByte(right[1:len(right)-1], right[0]) >= 0 {
+			return nil, unsupported
+		}
+		want = right[1 : len(right)-1]
+	}
+	if !xpathPredicateOperand.MatchString(left) {
+		return nil, unsupported
+	}
+
+	var out []*xmlNode
+	for _, n := range nodes {
+		var values []string
+		switch {
+		case strings.HasPrefix(left, "@"):
+			if v, ok := n.attr(left[1:]); ok {
+				values = append(values, v)
+			}
+		case left == "text()" || left == ".":
+			values = append(values, strings.TrimSpace(n.textContent()))
+		default:
+			for _, child := range xpathCandidates([]*xmlNode{n}, false, left) {
+				values = append(values, strings.TrimSpace(child.textContent()))
+			}
+		}
+		for _, v := range values {
+			if !hasValue || (v == want) != notEqual {
+				out = append(out, n)
+				break
+			}
+		}
+	}
+	return out, nil
+}
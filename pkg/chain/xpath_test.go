@@ -0,0 +1,135 @@
+package chain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestXPathLookup(t *testing.T) {
+	doc := `<?xml version="1.0"?>
+<catalog>
+  <book id="b1" lang="en"><title>Go</title><price>30</price></book>
+  <book id="b2"><title>Rust</title><price>35</price></book>
+  <magazine id="m1"><title>Wired</title></magazine>
+</catalog>`
+
+	root, err := parseXML([]byte(doc))
+	if err != nil {
+		t.Fatalf("parseXML failed: %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		expected interface{}
+	}{
+		{"/catalog/book[1]/title", "Go"},
+		{"/catalog/book[last()]/title", "Rust"},
+		{"//book/@id", []interface{}{"b1", "b2"}},
+		{"//book[@lang]/@id", "b1"},
+		{"//book[@id='b2']/price", "35"},
+		{"//book[title='Go']/price/text()", "30"},
+		{"//magazine/title", "Wired"},
+		{"/catalog/*[3]/@id", "m1"},
+		{"//title[.='Rust']/../@id", "b2"},
+		{"//@id", []interface{}{"b1", "b2", "m1"}},
+		{"//book[@id!='b1']/title", "Rust"},
+		{"//*[@lang!='fr']/@id", "b1"},
+		{"//book[title!='Go']/@id", "b2"},
+		{"//book/*/..//@lang", "en"},
+	}
+	for _, tt := range tests {
+		got, err := xpathLookup(root, tt.path)
+		if err != nil {
+			t.Errorf("xpathLookup(%q) failed: %v", tt.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("xpathLookup(%q) = %v, expected %v", tt.path, got, tt.expected)
+		}
+	}
+
+	for _, path := range []string{"//missing", "//book[", "//book/@id/title", "//book[@id!=b1]"} {
+		if _, err := xpathLookup(root, path); err == nil {
+			t.Errorf("expected xpathLookup(%q) to fail", path)
+		}
+	}
+
+	// Functions and operators outside the supported subset are rejected
+	// rather than matching nothing.
+	for path, want := range map[string]string{
+		"//book[contains(@lang,'e')]":      "unsupported XPath predicate '[contains(@lang,'e')]'",
+		"//book[position()<2]":             "unsupported XPath predicate '[position()<2]'",
+		"//book[@id='b1' or @id='b2']/@id": "unsupported XPath predicate '[@id='b1' or @id='b2']'",
+		"//book[@id='b1' and @lang='en']":  "unsupported XPath predicate '[@id='b1' and @lang='en']'",
+		"count(//book)":                    "unsupported XPath 'count(' in 'count(//book)'",
+		"//book/@*":                        "unsupported XPath '@*' in '//book/@*'",
+		"//book/title/string()":            "unsupported XPath 'string()' in '//book/title/string()'",
+	} {
+		_, err := xpathLookup(root, path)
+		if err == nil || err.Error() != want {
+			t.Errorf("xpathLookup(%q) error = %v, expected %q", path, err, want)
+		}
+	}
+}
+
+func TestXPathLookupNested(t *testing.T) {
+	doc := `<list><item id="1"><list><item id="2"><name>b</name></item></list><name>a</name></item></list>`
+	root, err := parseXML([]byte(doc))
+	if err != nil {
+		t.Fatalf("parseXML failed: %v", err)
+	}
+
+	// Nodes under several context nodes are matched once.
+	tests := []struct {
+		path     string
+		expected interface{}
+	}{
+		{"//list//item/@id", []interface{}{"1", "2"}},
+		{"//item//name", []interface{}{"b", "a"}},
+		{"//list//@id", []interface{}{"1", "2"}},
+		{"//name/..//@id", []interface{}{"2", "1"}},
+	}
+	for _, tt := range tests {
+		got, err := xpathLookup(root, tt.path)
+		if err != nil {
+			t.Errorf("xpathLookup(%q) failed: %v", tt.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("xpathLookup(%q) = %v, expected %v", tt.path, got, tt.expected)
+		}
+	}
+}
+
+func TestXPathLookupHTML(t *testing.T) {
+	doc := `<!DOCTYPE html>
+<html><head><title>Login</title></head>
+<body>
+  <form action="/session"><input type="hidden" name="csrf" value="tok&amp;1"><br>
+  <p>Welcome&nbsp;back
+</body></html>`
+
+	root, err := parseXML([]byte(doc))
+	if err != nil {
+		t.Fatalf("parseXML failed: %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		expected interface{}
+	}{
+		{"//title", "Login"},
+		{"//input[@name='csrf']/@value", "tok&1"},
+		{"//form/@action", "/session"},
+	}
+	for _, tt := range tests {
+		got, err := xpathLookup(root, tt.path)
+		if err != nil {
+			t.Errorf("xpathLookup(%q) failed: %v", tt.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("xpathLookup(%q) = %v, expected %v", tt.path, got, tt.expected)
+		}
+	}
+}